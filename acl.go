@@ -0,0 +1,338 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// for acltype
+const (
+	POSIX_ACL_ACCESS = iota + 1
+	POSIX_ACL_DEFAULT
+)
+
+// mask perm when the acl has no mask entry
+const ACL_NO_MASK = 0xFFFF
+
+// perm bits
+const (
+	ACL_EXECUTE = 1 << iota
+	ACL_WRITE
+	ACL_READ
+)
+
+type ACLEntry struct {
+	Id   uint32
+	Perm uint16
+}
+
+// posix acl, the same as getfacl output
+type ACL struct {
+	UserPerm    uint16
+	GroupPerm   uint16
+	OtherPerm   uint16
+	Mask        uint16
+	NamedUsers  []ACLEntry
+	NamedGroups []ACLEntry
+}
+
+func NewACL() *ACL {
+	return &ACL{
+		Mask:        ACL_NO_MASK,
+		NamedUsers:  make([]ACLEntry, 0),
+		NamedGroups: make([]ACLEntry, 0),
+	}
+}
+
+func (acl *ACL) HasMask() bool {
+	return acl.Mask != ACL_NO_MASK
+}
+
+func formatPerm(perm uint16) string {
+	p := []byte("---")
+	if perm&ACL_READ != 0 {
+		p[0] = 'r'
+	}
+	if perm&ACL_WRITE != 0 {
+		p[1] = 'w'
+	}
+	if perm&ACL_EXECUTE != 0 {
+		p[2] = 'x'
+	}
+	return string(p)
+}
+
+// rwx, r-x, rw or octal digit 0-7
+func parsePerm(str string) (perm uint16, err error) {
+	if len(str) == 1 && str[0] >= '0' && str[0] <= '7' {
+		perm = uint16(str[0] - '0')
+		return
+	}
+	for _, r := range str {
+		switch r {
+		case 'r', 'R':
+			perm |= ACL_READ
+		case 'w', 'W':
+			perm |= ACL_WRITE
+		case 'x', 'X':
+			perm |= ACL_EXECUTE
+		case '-':
+		default:
+			err = fmt.Errorf("invalid acl perm %s", str)
+			return
+		}
+	}
+	return
+}
+
+func parseId(str string, isUser bool) (id uint32, err error) {
+	n, e := strconv.ParseUint(str, 10, 32)
+	if e == nil {
+		id = uint32(n)
+		return
+	}
+	var sid string
+	if isUser {
+		u, e := user.Lookup(str)
+		if e != nil {
+			err = fmt.Errorf("unknown user %s", str)
+			return
+		}
+		sid = u.Uid
+	} else {
+		g, e := user.LookupGroup(str)
+		if e != nil {
+			err = fmt.Errorf("unknown group %s", str)
+			return
+		}
+		sid = g.Gid
+	}
+	n, err = strconv.ParseUint(sid, 10, 32)
+	id = uint32(n)
+	return
+}
+
+// textual format like getfacl, one entry per line, ids are numeric
+func (acl *ACL) String() string {
+	lines := []string{"user::" + formatPerm(acl.UserPerm)}
+	for _, e := range acl.NamedUsers {
+		lines = append(lines, fmt.Sprintf("user:%d:%s", e.Id, formatPerm(e.Perm)))
+	}
+	lines = append(lines, "group::"+formatPerm(acl.GroupPerm))
+	for _, e := range acl.NamedGroups {
+		lines = append(lines, fmt.Sprintf("group:%d:%s", e.Id, formatPerm(e.Perm)))
+	}
+	if acl.HasMask() {
+		lines = append(lines, "mask::"+formatPerm(acl.Mask))
+	}
+	lines = append(lines, "other::"+formatPerm(acl.OtherPerm))
+	return strings.Join(lines, "\n")
+}
+
+// parse textual format of getfacl or setfacl -m,
+// entries are separated by comma or newline, # starts a comment
+// user::rwx,user:1000:r-x,group::r-x,mask::rwx,other::r--
+func ParseACL(text string) (acl *ACL, err error) {
+	acl = NewACL()
+	text = strings.Replace(text, ",", "\n", -1)
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			err = fmt.Errorf("invalid acl entry %s", line)
+			return
+		}
+		var perm uint16
+		perm, err = parsePerm(parts[2])
+		if err != nil {
+			return
+		}
+		tag, qual := parts[0], parts[1]
+		switch tag {
+		case "u", "user":
+			if len(qual) == 0 {
+				acl.UserPerm = perm
+				continue
+			}
+			var id uint32
+			if id, err = parseId(qual, true); err != nil {
+				return
+			}
+			acl.NamedUsers = append(acl.NamedUsers, ACLEntry{id, perm})
+		case "g", "group":
+			if len(qual) == 0 {
+				acl.GroupPerm = perm
+				continue
+			}
+			var id uint32
+			if id, err = parseId(qual, false); err != nil {
+				return
+			}
+			acl.NamedGroups = append(acl.NamedGroups, ACLEntry{id, perm})
+		case "m", "mask":
+			acl.Mask = perm
+		case "o", "other":
+			acl.OtherPerm = perm
+		default:
+			err = fmt.Errorf("invalid acl entry %s", line)
+			return
+		}
+	}
+	sortEntries(acl.NamedUsers)
+	sortEntries(acl.NamedGroups)
+	return
+}
+
+func sortEntries(es []ACLEntry) {
+	sort.Slice(es, func(i, j int) bool { return es[i].Id < es[j].Id })
+}
+
+func parseACL(buf []byte) (acl *ACL, err error) {
+	if len(buf) < 12 {
+		err = fmt.Errorf("acl buf length is too short")
+		return
+	}
+	acl = NewACL()
+	var nusers, ngroups uint16
+	UnPack(buf, &acl.UserPerm, &acl.GroupPerm, &acl.OtherPerm, &acl.Mask,
+		&nusers, &ngroups)
+	if len(buf) != 12+6*(int(nusers)+int(ngroups)) {
+		err = fmt.Errorf("got wrong acl size %d", len(buf))
+		return
+	}
+	pos := 12
+	for i := 0; i < int(nusers)+int(ngroups); i++ {
+		var e ACLEntry
+		UnPack(buf[pos:], &e.Id, &e.Perm)
+		pos += 6
+		if i < int(nusers) {
+			acl.NamedUsers = append(acl.NamedUsers, e)
+		} else {
+			acl.NamedGroups = append(acl.NamedGroups, e)
+		}
+	}
+	return
+}
+
+func (acl *ACL) pack() []interface{} {
+	args := []interface{}{acl.UserPerm, acl.GroupPerm, acl.OtherPerm,
+		acl.Mask, uint16(len(acl.NamedUsers)), uint16(len(acl.NamedGroups))}
+	for _, e := range acl.NamedUsers {
+		args = append(args, e.Id, e.Perm)
+	}
+	for _, e := range acl.NamedGroups {
+		args = append(args, e.Id, e.Perm)
+	}
+	return args
+}
+
+func checkACLType(acltype uint8) (err error) {
+	if acltype != POSIX_ACL_ACCESS && acltype != POSIX_ACL_DEFAULT {
+		err = fmt.Errorf("invalid acl type %d", acltype)
+	}
+	return
+}
+
+func (c *MAClient) GetACL(inode uint32, acltype, opened uint8) (acl *ACL, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if err = checkACLType(acltype); err != nil {
+		return
+	}
+	var buf []byte
+	if c.Version.LessThan(3, 0, 92) {
+		buf, err = c.doCmd(CLTOMA_FUSE_GETFACL, 0, inode, acltype, opened,
+			c.ids())
+	} else {
+		buf, err = c.doCmd(CLTOMA_FUSE_GETFACL, 0, inode, acltype)
+	}
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 16)
+	if err != nil {
+		return
+	}
+	acl, err = parseACL(buf[4:])
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("get acl inode %d type %d users %d groups %d", inode,
+		acltype, len(acl.NamedUsers), len(acl.NamedGroups))
+	return
+}
+
+func (c *MAClient) SetACL(inode uint32, acltype uint8, acl *ACL) (err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if err = checkACLType(acltype); err != nil {
+		return
+	}
+	if acl == nil {
+		err = fmt.Errorf("acl is nil")
+		return
+	}
+	if len(acl.NamedUsers) > 0xffff || len(acl.NamedGroups) > 0xffff {
+		err = fmt.Errorf("too many acl entries")
+		return
+	}
+	args := []interface{}{0, inode, c.identity().Uid, acltype}
+	args = append(args, acl.pack()...)
+	buf, err := c.doCmd(CLTOMA_FUSE_SETFACL, args...)
+	if err != nil {
+		return
+	}
+	err = c.checkBuf(buf, 0, 5)
+	if err != nil {
+		return
+	}
+	err = getStatus(buf[4:])
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("set acl inode %d type %d", inode, acltype)
+	return
+}
+
+// acltype is POSIX_ACL_ACCESS or POSIX_ACL_DEFAULT
+func (c *Client) GetACL(path string, acltype uint8) (acl *ACL, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.GetACL(info.Inode, acltype, 0)
+}
+
+func (c *Client) SetACL(path string, acltype uint8, acl *ACL) (err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.SetACL(info.Inode, acltype, acl)
+}
@@ -0,0 +1,98 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestParseACL(t *testing.T) {
+	text := "user::rwx\nuser:1001:r-x\nuser:1000:rw-\n" +
+		"group::r-x # comment\ngroup:100:7\nmask::rwx\nother::r--\n"
+	acl, err := ParseACL(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.UserPerm != 7 || acl.GroupPerm != 5 || acl.OtherPerm != 4 ||
+		acl.Mask != 7 {
+		t.Error("unexpect", acl)
+	}
+	if len(acl.NamedUsers) != 2 || acl.NamedUsers[0].Id != 1000 ||
+		acl.NamedUsers[0].Perm != 6 {
+		t.Error("unexpect", acl.NamedUsers)
+	}
+	if len(acl.NamedGroups) != 1 || acl.NamedGroups[0].Perm != 7 {
+		t.Error("unexpect", acl.NamedGroups)
+	}
+	expect := "user::rwx\nuser:1000:rw-\nuser:1001:r-x\ngroup::r-x\n" +
+		"group:100:rwx\nmask::rwx\nother::r--"
+	if acl.String() != expect {
+		t.Error("unexpect", acl.String())
+	}
+	acl, err = ParseACL("u::rw,g::r,o::-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.HasMask() || acl.String() != "user::rw-\ngroup::r--\nother::---" {
+		t.Error("unexpect", acl.String())
+	}
+	_, err = ParseACL("user::rwz")
+	if err == nil {
+		t.Error("unexpect")
+	}
+	_, err = ParseACL("foo::rwx")
+	if err == nil {
+		t.Error("unexpect")
+	}
+}
+
+func TestPackACL(t *testing.T) {
+	acl, err := ParseACL("user::rwx,user:1000:r,group::r-x,mask::rwx,other::-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := Pack(acl.pack()...)
+	if len(buf) != 18 {
+		t.Fatal("unexpect size", len(buf))
+	}
+	acl2, err := parseACL(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.String() != acl2.String() {
+		t.Error("unexpect", acl2)
+	}
+	_, err = parseACL(buf[:16])
+	if err == nil {
+		t.Error("unexpect")
+	}
+}
+
+func TestACL(t *testing.T) {
+	t.Skip()
+	session(t, func(c *MAClient) {
+		n := "testfile"
+		c.Unlink(MFS_ROOT_ID, n)
+		fi, err := c.Create(MFS_ROOT_ID, n, 0744)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Unlink(MFS_ROOT_ID, n)
+		acl, _ := ParseACL("user::rwx,user:1000:r-x,group::r--,mask::r-x,other::---")
+		err = c.SetACL(fi.Inode, POSIX_ACL_ACCESS, acl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		acl2, err := c.GetACL(fi.Inode, POSIX_ACL_ACCESS, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acl.String() != acl2.String() {
+			t.Fatal("unexpect ", acl2)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"github.com/golang/glog"
+)
+
+// cmd of CLTOMA_FUSE_ARCHCTL
+const (
+	ARCHCTL_GET = 0
+	ARCHCTL_SET = 1
+	ARCHCTL_CLR = 2
+)
+
+// archive flag of chunks of a file or tree
+type ArchiveInfo struct {
+	ArchChunks    uint64
+	NotArchChunks uint64
+	ArchInodes    uint32
+	PartialInodes uint32 // some chunks are archived
+	NotArchInodes uint32
+}
+
+type ArchiveChange struct {
+	ChunksChanged      uint64
+	ChunksNotChanged   uint64
+	InodesNotPermitted uint32
+}
+
+func (c *MAClient) ArchiveStatus(inode uint32) (ai *ArchiveInfo, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_ARCHCTL, 0, inode, uint8(ARCHCTL_GET))
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 32)
+	if err != nil {
+		return
+	}
+	ai = new(ArchiveInfo)
+	UnPack(buf[4:], &ai.ArchChunks, &ai.NotArchChunks, &ai.ArchInodes,
+		&ai.PartialInodes, &ai.NotArchInodes)
+	glog.V(8).Infof("archive status inode %d chunks %d/%d", inode,
+		ai.ArchChunks, ai.ArchChunks+ai.NotArchChunks)
+	return
+}
+
+// cmd is ARCHCTL_SET or ARCHCTL_CLR
+func (c *MAClient) ArchCtl(inode uint32, cmd uint8) (ac *ArchiveChange, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if err = c.writable(); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_ARCHCTL, 0, inode, cmd, c.identity().Uid)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 24)
+	if err != nil {
+		return
+	}
+	ac = new(ArchiveChange)
+	UnPack(buf[4:], &ac.ChunksChanged, &ac.ChunksNotChanged, &ac.InodesNotPermitted)
+	glog.V(8).Infof("archctl inode %d cmd %d changed %d notchanged %d notpermitted %d",
+		inode, cmd, ac.ChunksChanged, ac.ChunksNotChanged, ac.InodesNotPermitted)
+	return
+}
+
+// like mfschkarchive, for all files under path if it is a directory
+func (c *Client) ArchiveStatus(path string) (ai *ArchiveInfo, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.ArchiveStatus(info.Inode)
+}
+
+// like mfssetarchive
+func (c *Client) SetArchive(path string) (ac *ArchiveChange, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.ArchCtl(info.Inode, ARCHCTL_SET)
+}
+
+// like mfsclrarchive
+func (c *Client) ClearArchive(path string) (ac *ArchiveChange, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.ArchCtl(info.Inode, ARCHCTL_CLR)
+}
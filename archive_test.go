@@ -0,0 +1,35 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestArchive(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ac, err := c.SetArchive("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(ac.ChunksChanged, ac.ChunksNotChanged, ac.InodesNotPermitted)
+	ai, err := c.ArchiveStatus("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ai.NotArchChunks != 0 {
+		t.Error("unexpect", ai)
+	}
+	if _, err = c.ClearArchive("/"); err != nil {
+		t.Fatal(err)
+	}
+}
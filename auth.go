@@ -0,0 +1,114 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"os"
+	"strings"
+)
+
+// mfsmaster rejected the passcode or needs one
+var ErrAuthFailed = errors.New("mfsmaster authentication failed")
+
+// md5 of password in hex like mfsmd5pass of mfsmount
+func ParseMD5Pass(s string) (digest [16]byte, err error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != 16 {
+		err = fmt.Errorf("md5pass must be 32 hex digits")
+		return
+	}
+	copy(digest[:], b)
+	return
+}
+
+// the first line of file, a file readable by group or others is
+// refused like the private keys of ssh
+func ReadPasswordFile(path string) (password string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		err = fmt.Errorf("password file %s is accessible by others, "+
+			"its mode %#o must be 0600 or stricter", path, perm)
+		return
+	}
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && len(line) == 0 {
+		err = fmt.Errorf("read password file %s error %v", path, err)
+		return
+	}
+	err = nil
+	password = strings.TrimRight(line, "\r\n")
+	return
+}
+
+// md5(random[0:16] + md5(password) + random[16:32])
+func passcodeOf(random []byte, digest [16]byte) []byte {
+	md := md5.New()
+	md.Write(random[:16])
+	md.Write(digest[:])
+	md.Write(random[16:])
+	return md.Sum(nil)
+}
+
+// md5 of Password or MD5Pass, ok is false if none of them is set
+func (c *MAClient) passDigest() (digest [16]byte, ok bool, err error) {
+	if len(c.Password) > 0 {
+		return md5.Sum([]byte(c.Password)), true, nil
+	}
+	if len(c.MD5Pass) > 0 {
+		digest, err = ParseMD5Pass(c.MD5Pass)
+		return digest, true, err
+	}
+	return
+}
+
+// answer of the password challenge, zeros without password
+func (c *MAClient) passcode() (code []byte, err error) {
+	digest, ok, err := c.passDigest()
+	if err != nil {
+		return
+	}
+	if !ok {
+		code = make([]byte, 16)
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_REGISTER, FUSE_REGISTER_BLOB_ACL,
+		REGISTER_GETRANDOM)
+	if err != nil {
+		err = fmt.Errorf("get random of password challenge error %v", err)
+		return
+	}
+	if len(buf) != 32 {
+		err = fmt.Errorf("got wrong size %d!=32 of random from mfsmaster", len(buf))
+		return
+	}
+	code = passcodeOf(buf, digest)
+	return
+}
+
+// status of register, password errors are ErrAuthFailed
+func authStatus(buf []byte) (err error) {
+	err = getStatus(buf)
+	if err == MFS_ERROR_BADPASSWORD || err == MFS_ERROR_NOPASSWORD {
+		glog.V(5).Infof("register session error %v", err)
+		err = ErrAuthFailed
+	}
+	return
+}
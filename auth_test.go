@@ -0,0 +1,102 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMD5Pass(t *testing.T) {
+	// mfsmd5pass of "secret"
+	digest, err := ParseMD5Pass("5ebe2294ecd0e0f08eab7690d2a6ee69\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != md5.Sum([]byte("secret")) {
+		t.Error("unexpect", digest)
+	}
+	for _, s := range []string{"", "5ebe2294", "zz" + "5ebe2294ecd0e0f08eab7690d2a6ee"} {
+		if _, err := ParseMD5Pass(s); err == nil {
+			t.Error("expect error", s)
+		}
+	}
+}
+
+func TestPassDigest(t *testing.T) {
+	c := NewMAClientPwd("", "", false)
+	if _, ok, err := c.passDigest(); ok || err != nil {
+		t.Error("unexpect", ok, err)
+	}
+	if code, err := c.passcode(); err != nil || !bytes.Equal(code, make([]byte, 16)) {
+		t.Error("unexpect", code, err)
+	}
+	c.MD5Pass = "5ebe2294ecd0e0f08eab7690d2a6ee69"
+	d1, ok, err := c.passDigest()
+	if !ok || err != nil {
+		t.Fatal("unexpect", ok, err)
+	}
+	c.Password = "secret"
+	d2, _, _ := c.passDigest()
+	if d1 != d2 {
+		t.Error("unexpect", d1, d2)
+	}
+	random := bytes.Repeat([]byte{1, 2}, 16)
+	want := md5.Sum(append(append(append([]byte{}, random[:16]...), d1[:]...), random[16:]...))
+	if code := passcodeOf(random, d1); !bytes.Equal(code, want[:]) {
+		t.Error("unexpect", code)
+	}
+	c.Password = ""
+	c.MD5Pass = "bad"
+	if _, err := c.passcode(); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestReadPasswordFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pass")
+	if err := ioutil.WriteFile(path, []byte("secret\r\nignored\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if pw, err := ReadPasswordFile(path); err != nil || pw != "secret" {
+		t.Error("unexpect", pw, err)
+	}
+	ioutil.WriteFile(path, []byte("nonewline"), 0600)
+	if pw, err := ReadPasswordFile(path); err != nil || pw != "nonewline" {
+		t.Error("unexpect", pw, err)
+	}
+	ioutil.WriteFile(path, nil, 0600)
+	if _, err := ReadPasswordFile(path); err == nil {
+		t.Error("expect error of empty file")
+	}
+	ioutil.WriteFile(path, []byte("secret"), 0600)
+	os.Chmod(path, 0640)
+	if _, err := ReadPasswordFile(path); err == nil {
+		t.Error("expect error of group readable file")
+	}
+}
+
+func TestAuthStatus(t *testing.T) {
+	if err := authStatus([]byte{uint8(MFS_ERROR_NOPASSWORD)}); err != ErrAuthFailed {
+		t.Error("unexpect", err)
+	}
+	if err := authStatus([]byte{uint8(MFS_ERROR_EACCES)}); err != MFS_ERROR_EACCES {
+		t.Error("unexpect", err)
+	}
+	if err := authStatus([]byte{0}); err != nil {
+		t.Error("unexpect", err)
+	}
+}
@@ -0,0 +1,205 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+)
+
+// the last copies count is 10 or more
+const CHECK_MAX_COPIES = 10
+
+// number of chunks by copies count
+type FileCheck struct {
+	Copies [CHECK_MAX_COPIES + 1]uint32 // 0 copies, 1 copy, ..., 10+ copies
+	Empty  uint32
+}
+
+func (fc *FileCheck) Chunks() (n uint32) {
+	for _, c := range fc.Copies {
+		n += c
+	}
+	return n + fc.Empty
+}
+
+// status of a chunk copy in the CHECK answer
+const (
+	CHECK_VALID            = 0
+	CHECK_MARKEDFORREMOVAL = 1
+	CHECK_WRONGVERSION     = 2
+	CHECK_WV_AND_MFR       = 3
+	CHECK_INVALID          = 4
+)
+
+// one copy of a chunk on chunkserver
+type ChunkReplica struct {
+	Ip   uint32
+	Port uint16
+	Type uint8 // CHECK_*
+}
+
+func (r *ChunkReplica) Addr() string {
+	return ipPortStr(r.Ip, r.Port)
+}
+
+func (r *ChunkReplica) IsValid() bool {
+	return r.Type == CHECK_VALID
+}
+
+// status label like mfsfileinfo
+func (r *ChunkReplica) Status() string {
+	switch r.Type {
+	case CHECK_VALID:
+		return "VALID"
+	case CHECK_MARKEDFORREMOVAL:
+		return "marked for removal"
+	case CHECK_WRONGVERSION:
+		return "wrong version"
+	case CHECK_WV_AND_MFR:
+		return "wrong version , marked for removal"
+	case CHECK_INVALID:
+		return "INVALID"
+	}
+	return "UNKNOWN"
+}
+
+type ChunkInfo struct {
+	Index    uint32
+	ChunkId  uint64
+	Version  uint32
+	Replicas []*ChunkReplica
+}
+
+// number of copies with CHECK_VALID status
+func (ci *ChunkInfo) ValidCopies() (n int) {
+	for _, r := range ci.Replicas {
+		if r.IsValid() {
+			n++
+		}
+	}
+	return
+}
+
+// a chunk never written
+func (ci *ChunkInfo) IsEmpty() bool {
+	return ci.ChunkId == 0 && ci.Version == 0
+}
+
+func ipStr(ip uint32) string {
+	return fmt.Sprintf("%d.%d.%d.%d", ip>>24, 0xff&(ip>>16), 0xff&(ip>>8), 0xff&ip)
+}
+
+func ipPortStr(ip uint32, port uint16) string {
+	return fmt.Sprintf("%s:%d", ipStr(ip), port)
+}
+
+func (c *MAClient) CheckFile(inode uint32) (fc *FileCheck, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_CHECK, 0, inode)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 52)
+	if err != nil {
+		return
+	}
+	fc = new(FileCheck)
+	UnPack(buf[4:], &fc.Copies, &fc.Empty)
+	glog.V(8).Infof("check file inode %d chunks %d", inode, fc.Chunks())
+	return
+}
+
+func (c *MAClient) CheckChunk(inode, index uint32) (ci *ChunkInfo, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_CHECK, 0, inode, index)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 16)
+	if err != nil {
+		return
+	}
+	if (len(buf)-16)%7 != 0 {
+		err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+		return
+	}
+	ci = &ChunkInfo{
+		Index:    index,
+		Replicas: make([]*ChunkReplica, 0),
+	}
+	UnPack(buf[4:], &ci.ChunkId, &ci.Version)
+	for pos := 16; pos < len(buf); pos += 7 {
+		r := new(ChunkReplica)
+		UnPack(buf[pos:], &r.Ip, &r.Port, &r.Type)
+		ci.Replicas = append(ci.Replicas, r)
+	}
+	glog.V(8).Infof("check chunk inode %d index %d cid %d ver %d copies %d",
+		inode, index, ci.ChunkId, ci.Version, len(ci.Replicas))
+	return
+}
+
+// chunks count of a file with size
+func chunksOf(size uint64) uint32 {
+	return uint32((size + MFSCHUNKMASK) >> MFSCHUNKBITS)
+}
+
+// like mfscheckfile
+func (c *Client) CheckFile(path string) (fc *FileCheck, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.CheckFile(info.Inode)
+}
+
+// like mfsfileinfo, one ChunkInfo for each chunk index of the file
+func (c *Client) FileChunks(path string) (chunks []*ChunkInfo, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	if !info.IsFile() {
+		err = fmt.Errorf("%s is not a file", path)
+		return
+	}
+	return c.mc.FileChunks(info.Inode, info.Size)
+}
+
+func (c *MAClient) FileChunks(inode uint32, size uint64) (chunks []*ChunkInfo, err error) {
+	n := chunksOf(size)
+	chunks = make([]*ChunkInfo, 0, n)
+	for i := uint32(0); i < n; i++ {
+		var ci *ChunkInfo
+		ci, err = c.CheckChunk(inode, i)
+		if err != nil {
+			return
+		}
+		chunks = append(chunks, ci)
+	}
+	return
+}
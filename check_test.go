@@ -0,0 +1,82 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestChunksOf(t *testing.T) {
+	if chunksOf(0) != 0 {
+		t.Error("unexpect")
+	}
+	if chunksOf(1) != 1 {
+		t.Error("unexpect")
+	}
+	if chunksOf(MFSCHUNKSIZE) != 1 {
+		t.Error("unexpect")
+	}
+	if chunksOf(MFSCHUNKSIZE+1) != 2 {
+		t.Error("unexpect")
+	}
+}
+
+func TestChunkReplicaAddr(t *testing.T) {
+	r := &ChunkReplica{Ip: 0x0a000102, Port: 9422}
+	if r.Addr() != "10.0.1.2:9422" {
+		t.Error("unexpect", r.Addr())
+	}
+}
+
+func TestChunkValidCopies(t *testing.T) {
+	ci := &ChunkInfo{Replicas: []*ChunkReplica{
+		{Type: CHECK_VALID},
+		{Type: CHECK_WRONGVERSION},
+		{Type: CHECK_INVALID},
+	}}
+	if ci.ValidCopies() != 1 {
+		t.Error("unexpect", ci.ValidCopies())
+	}
+	if ci.Replicas[1].Status() != "wrong version" {
+		t.Error("unexpect", ci.Replicas[1].Status())
+	}
+	if (&ChunkReplica{Type: 9}).Status() != "UNKNOWN" {
+		t.Error("unexpect")
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	t.Skip()
+	session(t, func(c *MAClient) {
+		n := "testfile"
+		c.Unlink(MFS_ROOT_ID, n)
+		fi, err := c.Create(MFS_ROOT_ID, n, 0744)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Unlink(MFS_ROOT_ID, n)
+		_, err = c.CheckFile(fi.Inode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs, err := c.WriteChunk(fi.Inode, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.WriteChunkEnd(cs.ChunkId, fi.Inode, 0, cs.Length, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ci, err := c.CheckChunk(fi.Inode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ci.ChunkId != cs.ChunkId {
+			t.Fatal("unexpect ", ci.ChunkId)
+		}
+	})
+}
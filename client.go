@@ -7,43 +7,63 @@ Copyright (c) 2019 DHacky
 */
 
 import (
-	"flag"
+	"errors"
 	"fmt"
 	"github.com/golang/glog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 )
 
-var (
-	masterAddr   string
-	masterPsw    string
-	masterSubdir string
-)
-
-func init() {
-	flag.StringVar(&masterAddr, "H", "", "mfs master host")
-	flag.StringVar(&masterPsw, "P", "", "mfs master password")
-	flag.StringVar(&masterSubdir, "p", "", "mfs remote sub path in mfs tree")
-}
-
 type Client struct {
 	mc        *MAClient
 	Cwd       string
 	currInode uint32
+	lockMu    sync.Mutex
+	locked    map[*File]bool // files holding locks
+	toolsMu   sync.Mutex
+	tools     *MAClient // without session, for the commands of mfscli
+	root      *Client   // the client owning the session of a view
 }
 
 type File struct {
-	Path   string
-	info   *FileInfo
-	client *Client
+	Path    string
+	info    *FileInfo
+	client  *Client
+	owner   uint64                // flock owner
+	flocked bool                  // flock is held
+	owners  map[uint64]lockRanges // posix lock owners and their ranges
+}
+
+func (c *Client) newFile(path string, info *FileInfo) *File {
+	return &File{
+		Path:   path,
+		info:   info,
+		client: c,
+		owner:  newLockOwner(),
+		owners: make(map[uint64]lockRanges),
+	}
+}
+
+func NewClientFull(addr, password, subDir string) (*Client, error) {
+	return newClient(NewMAClientPwd(addr, password, true), subDir)
 }
 
-func NewClientFull(addr, password, subDir string) (c *Client, err error) {
+// like NewClientFull with the md5 of password, see ParseMD5Pass
+func NewClientMD5(addr, md5pass, subDir string) (*Client, error) {
+	mc := NewMAClientPwd(addr, "", true)
+	mc.MD5Pass = md5pass
+	return newClient(mc, subDir)
+}
+
+func newClient(mc *MAClient, subDir string) (c *Client, err error) {
 	c = &Client{
-		mc:        NewMAClientPwd(addr, password, true),
+		mc:        mc,
 		Cwd:       "/",
 		currInode: MFS_ROOT_ID,
+		locked:    make(map[*File]bool),
 	}
 	// the default Subdir is /
 	if len(subDir) > 0 {
@@ -60,22 +80,61 @@ func NewClientFull(addr, password, subDir string) (c *Client, err error) {
 	return
 }
 
+// client of the mfsmaster from the environment, profile and
+// mfsmount.cfg, see LoadConfig
 func NewClient() (c *Client, err error) {
-	return NewClientFull(masterAddr, masterPsw, masterSubdir)
+	cfg, err := LoadConfig(nil, "")
+	if err != nil {
+		return
+	}
+	return cfg.NewClient()
 }
 
 // close client, not file
+// locks held by files are released before the session is closed
 func (c *Client) Close() {
+	if c.root != nil {
+		return
+	}
 	if c.mc != nil {
+		c.releaseLocks()
 		c.mc.CloseSession()
 		c.mc.Close()
 		c.mc = nil
 	}
+	c.toolsMu.Lock()
+	if c.tools != nil {
+		c.tools.Close()
+		c.tools = nil
+	}
+	c.toolsMu.Unlock()
+}
+
+func (c *Client) Statfs() (st *StatInfo, err error) {
+	if _, err = c.check(""); err != nil {
+		return
+	}
+	return c.mc.Statfs()
+}
+
+// connection to the same mfsmaster without session, mfsmaster answers
+// the commands of mfscli like CLTOMA_INFO only on such connection
+func (c *Client) Tools() (tools *MAClient, err error) {
+	if _, err = c.check(""); err != nil {
+		return
+	}
+	c = c.owner()
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+	if c.tools == nil {
+		c.tools = NewTools(c.mc.addr)
+	}
+	return c.tools, nil
 }
 
 // check master connection and the length of path
 func (c *Client) check(path string) (p string, err error) {
-	if c.mc == nil {
+	if c.mc == nil || c.owner().mc == nil {
 		err = fmt.Errorf("client is closed")
 		return
 	}
@@ -131,11 +190,7 @@ func (c *Client) Open(path string, flags uint8) (f *File, err error) {
 	if err != nil {
 		return
 	}
-	f = &File{
-		Path:   path,
-		info:   info,
-		client: c,
-	}
+	f = c.newFile(path, info)
 	return
 }
 
@@ -148,11 +203,7 @@ func (c *Client) Create(path string) (f *File, err error) {
 	if err != nil {
 		return
 	}
-	f = &File{
-		Path:   path,
-		info:   fi,
-		client: c,
-	}
+	f = c.newFile(path, fi)
 	return
 }
 
@@ -208,6 +259,11 @@ func (f *File) Length() string {
 	return f.info.GetSize()
 }
 
+// release locks held by the file
+func (f *File) Close() (err error) {
+	return f.releaseLocks()
+}
+
 // write one chunk by one
 func (f *File) Write(buf []byte, offset uint64) (n uint32, err error) {
 	size := uint32(len(buf))
@@ -314,6 +370,54 @@ func (c *Client) Readdir(path string) (infoMap ReaddirInfoMap, err error) {
 	return c.mc.Readdir(info.Inode)
 }
 
+// called for each file or dir by Walk, return SkipDir to skip a dir
+type WalkFunc func(path string, info *FileInfo) error
+
+var SkipDir = errors.New("skip this directory")
+
+// walk the tree rooted at path in lexical order, like filepath.Walk
+func (c *Client) Walk(path string, fn WalkFunc) (err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	err = c.walk(path, info, fn)
+	if err == SkipDir {
+		err = nil
+	}
+	return
+}
+
+func (c *Client) walk(path string, info *FileInfo, fn WalkFunc) (err error) {
+	err = fn(path, info)
+	if err != nil || !info.IsDir() {
+		return
+	}
+	names := make([]string, 0)
+	entries := make(map[string]*FileInfo)
+	err = c.mc.ReaddirEach(info.Inode, true, func(e *DirEntry) error {
+		if e.Name != "." && e.Name != ".." {
+			names = append(names, e.Name)
+			entries[e.Name] = e.Info
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		err = c.walk(filepath.Join(path, n), entries[n], fn)
+		if err == SkipDir && entries[n].IsDir() {
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
 func (c *Client) GetDirStats(path string) (ds *DirStats, err error) {
 	_, info, err := c.lookup(path)
 	if err != nil {
@@ -14,12 +14,13 @@ import (
 	"testing"
 )
 
+// the integration tests use a local mfsmaster unless MFS_MASTER is set
 func init() {
 	flag.Set("logtostderr", "true")
 	flag.Set("v", "10")
-	flag.Set("H", "127.0.0.1")
-	flag.Set("P", "")
-	flag.Parse()
+	if len(os.Getenv(ENV_MASTER)) == 0 {
+		os.Setenv(ENV_MASTER, "127.0.0.1")
+	}
 }
 
 func TestWrite(t *testing.T) {
@@ -8,22 +8,38 @@ Copyright (c) 2019 DHacky
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	mfs "github.com/Hacky-DH/moosefs-client"
+	"github.com/Hacky-DH/moosefs-client/exporter"
+	"github.com/Hacky-DH/moosefs-client/quotawatch"
 	"github.com/golang/glog"
 	"github.com/google/subcommands"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 )
 
 var (
 	version    bool
 	versionstr string
+	profile    string
+	flagConfig mfs.Config
 )
 
 func init() {
 	flag.BoolVar(&version, "version", false, "show version")
+	flag.StringVar(&flagConfig.Master, "H", "", "mfs master host")
+	flag.StringVar(&flagConfig.Password, "P", "", "mfs master password")
+	flag.StringVar(&flagConfig.PassFile, "passfile", "",
+		"read mfs master password from file")
+	flag.StringVar(&flagConfig.MD5Pass, "md5pass", "",
+		"md5 of mfs master password like mfsmd5pass")
+	flag.StringVar(&flagConfig.Subfolder, "p", "", "mfs remote sub path in mfs tree")
+	flag.StringVar(&profile, "profile", "", "cluster profile in "+mfs.ProfilePath())
 	flag.Set("logtostderr", "true")
 	flag.Set("v", "1")
 }
@@ -46,7 +62,7 @@ func (s *uploadCmd) Execute(_ context.Context, f *flag.FlagSet,
 		f.Usage()
 		return subcommands.ExitUsageError
 	}
-	c, err := mfs.NewClient()
+	c, err := newClient()
 	if err != nil {
 		glog.Error(err)
 		return subcommands.ExitFailure
@@ -82,7 +98,7 @@ func (s *downloadCmd) Execute(_ context.Context, f *flag.FlagSet,
 		f.Usage()
 		return subcommands.ExitUsageError
 	}
-	c, err := mfs.NewClient()
+	c, err := newClient()
 	if err != nil {
 		glog.Error(err)
 		return subcommands.ExitFailure
@@ -113,7 +129,7 @@ func (s *lsCmd) SetFlags(f *flag.FlagSet) {
 }
 func (s *lsCmd) Execute(_ context.Context, f *flag.FlagSet,
 	_ ...interface{}) subcommands.ExitStatus {
-	c, err := mfs.NewClient()
+	c, err := newClient()
 	if err != nil {
 		glog.Error(err)
 		return subcommands.ExitFailure
@@ -165,7 +181,7 @@ func (s *removeCmd) Execute(_ context.Context, f *flag.FlagSet,
 		f.Usage()
 		return subcommands.ExitUsageError
 	}
-	c, err := mfs.NewClient()
+	c, err := newClient()
 	if err != nil {
 		glog.Error(err)
 		return subcommands.ExitFailure
@@ -197,7 +213,7 @@ func (s *mkdirCmd) Execute(_ context.Context, f *flag.FlagSet,
 		f.Usage()
 		return subcommands.ExitUsageError
 	}
-	c, err := mfs.NewClient()
+	c, err := newClient()
 	if err != nil {
 		glog.Error(err)
 		return subcommands.ExitFailure
@@ -229,7 +245,7 @@ func (s *rmdirCmd) Execute(_ context.Context, f *flag.FlagSet,
 		f.Usage()
 		return subcommands.ExitUsageError
 	}
-	c, err := mfs.NewClient()
+	c, err := newClient()
 	if err != nil {
 		glog.Error(err)
 		return subcommands.ExitFailure
@@ -245,6 +261,1034 @@ func (s *rmdirCmd) Execute(_ context.Context, f *flag.FlagSet,
 	return subcommands.ExitSuccess
 }
 
+// flag parsing stops at the action word, parse the flags following it
+// again, so both "cmd -m x set ..." and "cmd set -m x ..." work
+func parseAfterAction(f *flag.FlagSet) error {
+	if f.NArg() < 1 {
+		return nil
+	}
+	action := f.Arg(0)
+	if err := f.Parse(f.Args()[1:]); err != nil {
+		return err
+	}
+	return f.Parse(append([]string{action}, f.Args()...))
+}
+
+type xattrCmd struct {
+	mode string
+}
+
+func (*xattrCmd) Name() string     { return "xattr" }
+func (*xattrCmd) Synopsis() string { return "get, set, list or remove extended attributes" }
+func (s *xattrCmd) Usage() string {
+	return fmt.Sprintf("%s get <path> <name>\n"+
+		"%s set [-m mode] <path> <name> <value>\n"+
+		"%s ls <path>\n"+
+		"%s rm <path> <name>\n\t%s\n",
+		s.Name(), s.Name(), s.Name(), s.Name(), s.Synopsis())
+}
+func (s *xattrCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.mode, "m", "", "set mode: create or replace, default both")
+}
+func (s *xattrCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if err := parseAfterAction(f); err != nil {
+		return subcommands.ExitUsageError
+	}
+	nargs := map[string]int{"get": 3, "set": 4, "ls": 2, "rm": 3}
+	if f.NArg() < 2 || nargs[f.Arg(0)] != f.NArg() {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	var mode uint8
+	switch s.mode {
+	case "":
+		mode = mfs.XATTR_CREATE_OR_REPLACE
+	case "create":
+		mode = mfs.XATTR_CREATE_ONLY
+	case "replace":
+		mode = mfs.XATTR_REPLACE_ONLY
+	default:
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	path := f.Arg(1)
+	switch f.Arg(0) {
+	case "get":
+		var value []byte
+		value, err = c.Getxattr(path, f.Arg(2))
+		if err == nil {
+			fmt.Printf("%s=\"%s\"\n", f.Arg(2), value)
+		}
+	case "set":
+		err = c.Setxattr(path, f.Arg(2), []byte(f.Arg(3)), mode)
+	case "ls":
+		var names []string
+		names, err = c.Listxattr(path)
+		for _, n := range names {
+			fmt.Println(n)
+		}
+	case "rm":
+		err = c.Removexattr(path, f.Arg(2))
+	}
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type snapshotCmd struct {
+	overwrite bool
+	umask     uint
+	keep      int
+}
+
+func (*snapshotCmd) Name() string     { return "snapshot" }
+func (*snapshotCmd) Synopsis() string { return "make a copy-on-write snapshot of file or dir" }
+func (s *snapshotCmd) Usage() string {
+	return fmt.Sprintf("%s [-o] [-m umask] <src> <dst>\n"+
+		"%s -keep N [-m umask] <src> <dir>\n\t%s\n", s.Name(), s.Name(), s.Synopsis())
+}
+func (s *snapshotCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&s.overwrite, "o", false, "overwrite existing dst")
+	f.UintVar(&s.umask, "m", 022, "umask of snapshot")
+	f.IntVar(&s.keep, "keep", 0, "rotate snapshots in dir, keeping the newest N")
+}
+func (s *snapshotCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	if s.keep > 0 {
+		var name string
+		name, err = c.RotateSnapshots(f.Arg(0), f.Arg(1), s.keep, uint16(s.umask))
+		if err == nil {
+			glog.Infof("snapshot %s to %s", f.Arg(0), name)
+		}
+	} else {
+		err = c.Snapshot(f.Arg(0), f.Arg(1), s.overwrite, uint16(s.umask))
+	}
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type fileinfoCmd struct {
+}
+
+func (*fileinfoCmd) Name() string     { return "fileinfo" }
+func (*fileinfoCmd) Synopsis() string { return "show chunks and their copies of files" }
+func (s *fileinfoCmd) Usage() string {
+	return fmt.Sprintf("%s <path> ...\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *fileinfoCmd) SetFlags(f *flag.FlagSet) {
+}
+func (s *fileinfoCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() < 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	for _, path := range f.Args() {
+		chunks, err := c.FileChunks(path)
+		if err != nil {
+			glog.Error(err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("%s:\n", path)
+		for _, ci := range chunks {
+			if ci.IsEmpty() {
+				fmt.Printf("\tchunk %d: empty\n", ci.Index)
+				continue
+			}
+			fmt.Printf("\tchunk %d: %016X_%08X / (id:%d ver:%d)\n",
+				ci.Index, ci.ChunkId, ci.Version, ci.ChunkId, ci.Version)
+			if ci.ValidCopies() == 0 {
+				fmt.Printf("\t\tno valid copies !!!\n")
+			}
+			for i, r := range ci.Replicas {
+				fmt.Printf("\t\tcopy %d: %s (status:%s)\n", i+1, r.Addr(), r.Status())
+			}
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+type checkfileCmd struct {
+}
+
+func (*checkfileCmd) Name() string     { return "checkfile" }
+func (*checkfileCmd) Synopsis() string { return "show number of chunks by copies count of files" }
+func (s *checkfileCmd) Usage() string {
+	return fmt.Sprintf("%s <path> ...\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *checkfileCmd) SetFlags(f *flag.FlagSet) {
+}
+func (s *checkfileCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() < 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	for _, path := range f.Args() {
+		fc, err := c.CheckFile(path)
+		if err != nil {
+			glog.Error(err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("%s:\n", path)
+		for i, n := range fc.Copies {
+			if n == 0 {
+				continue
+			}
+			switch i {
+			case 1:
+				fmt.Printf(" chunks with 1 copy:    %10d\n", n)
+			case mfs.CHECK_MAX_COPIES:
+				fmt.Printf(" chunks with 10+ copies:%10d\n", n)
+			default:
+				fmt.Printf(" chunks with %d copies:  %10d\n", i, n)
+			}
+		}
+		if fc.Empty > 0 {
+			fmt.Printf(" empty chunks:          %10d\n", fc.Empty)
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+type verifyCmd struct {
+	local string
+}
+
+func (*verifyCmd) Name() string     { return "verify" }
+func (*verifyCmd) Synopsis() string { return "verify block checksums of all copies of a file" }
+func (s *verifyCmd) Usage() string {
+	return fmt.Sprintf("%s [-l local file] <path>\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *verifyCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.local, "l", "", "local reference copy of the file")
+}
+func (s *verifyCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	report, err := c.VerifyFile(f.Arg(0), s.local)
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	for _, cv := range report.Chunks {
+		if cv.OK() {
+			continue
+		}
+		fmt.Printf("chunk %d: %016X_%08X\n", cv.Index, cv.ChunkId, cv.Version)
+		if cv.ValidCopies() == 0 {
+			fmt.Printf("\tno valid copies !!!\n")
+		}
+		for _, rv := range cv.Replicas {
+			switch {
+			case rv.Stale:
+				fmt.Printf("\t%s: stale\n", rv.Replica.Addr())
+			case rv.Err != nil:
+				fmt.Printf("\t%s: %v\n", rv.Replica.Addr(), rv.Err)
+			case len(rv.Blocks) > 0:
+				fmt.Printf("\t%s: %d blocks differ, first %d\n",
+					rv.Replica.Addr(), len(rv.Blocks), rv.Blocks[0])
+			}
+		}
+	}
+	if !report.OK() {
+		return subcommands.ExitFailure
+	}
+	fmt.Printf("%s: ok\n", f.Arg(0))
+	return subcommands.ExitSuccess
+}
+
+type scrubCmd struct {
+	workers int
+	bw      string
+	repair  bool
+}
+
+func (*scrubCmd) Name() string     { return "scrub" }
+func (*scrubCmd) Synopsis() string { return "read all copies of all files under a directory" }
+func (s *scrubCmd) Usage() string {
+	return fmt.Sprintf("%s [-j workers] [-bw bytes per second] [-repair] <path>\n\t%s\n",
+		s.Name(), s.Synopsis())
+}
+func (s *scrubCmd) SetFlags(f *flag.FlagSet) {
+	f.IntVar(&s.workers, "j", 1, "files scrubbed in parallel")
+	f.StringVar(&s.bw, "bw", "", "read bandwidth limit, e.g. 50Mi")
+	f.BoolVar(&s.repair, "repair", false, "repair files with bad chunks")
+}
+func (s *scrubCmd) Execute(ctx context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	opt := &mfs.ScrubOptions{Workers: s.workers, Repair: s.repair}
+	if len(s.bw) > 0 {
+		bw, err := mfs.ParseBytes(s.bw)
+		if err != nil {
+			glog.Error(err)
+			return subcommands.ExitUsageError
+		}
+		opt.BytesPerSec = bw
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	report, err := c.Scrub(ctx, f.Arg(0), opt)
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	for _, e := range report.Errors {
+		if e.ChunkId == 0 {
+			fmt.Printf("%s: %v\n", e.Path, e.Err)
+			continue
+		}
+		if len(e.Replica) == 0 {
+			fmt.Printf("%s chunk %d: %016X_%08X %v\n", e.Path, e.Index,
+				e.ChunkId, e.Version, e.Err)
+			continue
+		}
+		fmt.Printf("%s chunk %d: %016X_%08X %s block %d %v\n", e.Path, e.Index,
+			e.ChunkId, e.Version, e.Replica, e.Block, e.Err)
+	}
+	fmt.Printf("files: %d chunks: %d read: %s errors: %d\n", report.Files,
+		report.Chunks, mfs.FormatBytes(float64(report.Bytes), mfs.Binary), len(report.Errors))
+	if s.repair {
+		fmt.Printf("repaired files: %d chunks not changed: %d erased: %d repaired: %d\n",
+			len(report.Repaired), report.NotChanged, report.Erased, report.Fixed)
+	}
+	if len(report.Errors) > 0 {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type undergoalCmd struct {
+	goal   int
+	format string
+}
+
+func (*undergoalCmd) Name() string     { return "undergoal" }
+func (*undergoalCmd) Synopsis() string { return "find files with missing or undergoal chunks" }
+func (s *undergoalCmd) Usage() string {
+	return fmt.Sprintf("%s [-g goal] [-f text|json|csv] <path>\n\t%s\n",
+		s.Name(), s.Synopsis())
+}
+func (s *undergoalCmd) SetFlags(f *flag.FlagSet) {
+	f.IntVar(&s.goal, "g", 2, "copies required by storage classes not named by goal")
+	f.StringVar(&s.format, "f", "text", "output format: text, json or csv")
+}
+func (s *undergoalCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	report, err := c.ScanReplication(f.Arg(0), s.goal)
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	switch s.format {
+	case "json":
+		err = report.WriteJSON(os.Stdout)
+	case "csv":
+		err = report.WriteCSV(os.Stdout)
+	case "text":
+		for _, fr := range report.AtRisk {
+			fmt.Printf("%s: goal %d missing %d undergoal %d\n", fr.Path,
+				fr.Goal, fr.Missing, fr.Undergoal)
+		}
+		fmt.Printf("files: %d chunks: %d missing: %d undergoal: %d\n",
+			report.Files, report.Chunks, report.Missing, report.Undergoal)
+		for n, cnt := range report.Copies {
+			if cnt > 0 {
+				fmt.Printf(" chunks with %d copies: %d\n", n, cnt)
+			}
+		}
+	default:
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	if report.Missing > 0 || report.Undergoal > 0 {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type infoCmd struct {
+	json bool
+}
+
+func (*infoCmd) Name() string     { return "info" }
+func (*infoCmd) Synopsis() string { return "show mfsmaster information" }
+func (s *infoCmd) Usage() string {
+	return fmt.Sprintf("%s [-json]\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *infoCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&s.json, "json", false, "output in json")
+}
+func (s *infoCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	info, err := c.Info()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	if s.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(info); err != nil {
+			glog.Error(err)
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+	bytes := func(b uint64) string {
+		return mfs.FormatBytes(float64(b), mfs.Binary)
+	}
+	fmt.Printf("version:          %s\n", info.VersionStr)
+	fmt.Printf("state:            %s\n", info.StateStr())
+	if info.State != mfs.MASTER_STATE_MASTER && info.LeaderIp != 0 {
+		fmt.Printf("leader:           %s\n", info.LeaderIpStr())
+	}
+	fmt.Printf("metadata version: %d\n", info.MetaVersion)
+	fmt.Printf("memory usage:     %s\n", bytes(info.MemUsage))
+	fmt.Printf("cpu usage:        %.2f%% (sys %.2f%% user %.2f%%)\n",
+		float64(info.SysCpu+info.UserCpu)/1e4,
+		float64(info.SysCpu)/1e4, float64(info.UserCpu)/1e4)
+	fmt.Printf("total space:      %s\n", bytes(info.TotalSpace))
+	fmt.Printf("avail space:      %s\n", bytes(info.AvailSpace))
+	fmt.Printf("trash space:      %s (%d files)\n", bytes(info.TrashSpace), info.TrashNodes)
+	fmt.Printf("sustained space:  %s (%d files)\n", bytes(info.SustainedSpace),
+		info.SustainedNodes)
+	fmt.Printf("all fs objects:   %d (%d dirs, %d files)\n", info.AllNodes,
+		info.DirNodes, info.FileNodes)
+	fmt.Printf("chunks:           %d (%d copies, %d marked for removal)\n",
+		info.Chunks, info.ChunkCopies, info.TdCopies)
+	if info.LastStoreTs > 0 {
+		fmt.Printf("last metadata save: %s in %.3fs status %d\n",
+			time.Unix(int64(info.LastStoreTs), 0).Format(time.RFC3339),
+			float64(info.LastStoreDuration)/1000, info.LastStoreStatus)
+	}
+	return subcommands.ExitSuccess
+}
+
+type sessionsCmd struct {
+	json  bool
+	stats bool
+}
+
+func (*sessionsCmd) Name() string     { return "sessions" }
+func (*sessionsCmd) Synopsis() string { return "list or kill sessions of mfsmaster" }
+func (s *sessionsCmd) Usage() string {
+	return fmt.Sprintf("%s ls [-json] [-stats]\n"+
+		"%s kill <id>\n\t%s\n", s.Name(), s.Name(), s.Synopsis())
+}
+func (s *sessionsCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&s.json, "json", false, "output in json")
+	f.BoolVar(&s.stats, "stats", false, "show operation stats of the last hour")
+}
+func (s *sessionsCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if err := parseAfterAction(f); err != nil {
+		return subcommands.ExitUsageError
+	}
+	nargs := map[string]int{"ls": 1, "kill": 2}
+	if f.NArg() < 1 || nargs[f.Arg(0)] != f.NArg() {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	switch f.Arg(0) {
+	case "ls":
+		var sessions []*mfs.SessionInfo
+		sessions, err = c.Sessions()
+		if err != nil {
+			break
+		}
+		if s.json {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(sessions)
+			break
+		}
+		for _, ses := range sessions {
+			fmt.Printf("%d %s %s open files %d expire %d %s %s [%s]\n", ses.Id,
+				ses.IpStr(), ses.Version, ses.OpenFiles, ses.Expire, ses.Path,
+				ses.FlagsStr(), ses.Info)
+			if !s.stats {
+				continue
+			}
+			for i, v := range ses.Last {
+				if i < len(mfs.SessionStatNames) && v > 0 {
+					fmt.Printf("\t%s: %d\n", mfs.SessionStatNames[i], v)
+				}
+			}
+		}
+	case "kill":
+		var id uint64
+		id, err = strconv.ParseUint(f.Arg(1), 10, 32)
+		if err != nil {
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+		err = c.RemoveSession(uint32(id))
+	}
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type quotaCmd struct {
+	limits map[uint8]*string
+	grace  time.Duration
+	json   bool
+}
+
+func (*quotaCmd) Name() string     { return "quota" }
+func (*quotaCmd) Synopsis() string { return "get, set, delete or report quotas" }
+func (s *quotaCmd) Usage() string {
+	return fmt.Sprintf("%s get [-json] <path>\n"+
+		"%s set [-si inodes] [-sl length] [-ss size] [-sr realsize] "+
+		"[-hi inodes] [-hl length] [-hs size] [-hr realsize] [-g grace] <path>\n"+
+		"%s del <path> [sinodes,slength,ssize,srealsize,hinodes,hlength,hsize,hrealsize]\n"+
+		"%s report [-json]\n\t%s\n",
+		s.Name(), s.Name(), s.Name(), s.Name(), s.Synopsis())
+}
+func (s *quotaCmd) SetFlags(f *flag.FlagSet) {
+	s.limits = make(map[uint8]*string)
+	for _, l := range []struct {
+		flag       uint8
+		name, desc string
+	}{
+		{mfs.QUOTA_FLAG_SINODES, "si", "soft inodes"},
+		{mfs.QUOTA_FLAG_SLENGTH, "sl", "soft length"},
+		{mfs.QUOTA_FLAG_SSIZE, "ss", "soft size"},
+		{mfs.QUOTA_FLAG_SREALSIZE, "sr", "soft real size"},
+		{mfs.QUOTA_FLAG_HINODES, "hi", "hard inodes"},
+		{mfs.QUOTA_FLAG_HLENGTH, "hl", "hard length"},
+		{mfs.QUOTA_FLAG_HSIZE, "hs", "hard size"},
+		{mfs.QUOTA_FLAG_HREALSIZE, "hr", "hard real size"},
+	} {
+		s.limits[l.flag] = f.String(l.name, "", l.desc+" limit, e.g. 10Ti")
+	}
+	f.DurationVar(&s.grace, "g", 0, "grace period of soft limits, default of mfsmaster if 0")
+	f.BoolVar(&s.json, "json", false, "output in json")
+}
+
+// parse the limits given in flags
+func (s *quotaCmd) quota() (q *mfs.Quota, err error) {
+	q = &mfs.Quota{GracePeriod: uint32(s.grace / time.Second)}
+	for flag, str := range s.limits {
+		if len(*str) == 0 {
+			continue
+		}
+		var v uint64
+		v, err = mfs.ParseBytes(*str)
+		if err != nil {
+			return
+		}
+		q.Flags |= flag
+		switch flag {
+		case mfs.QUOTA_FLAG_SINODES:
+			q.SoftInodes = uint32(v)
+		case mfs.QUOTA_FLAG_SLENGTH:
+			q.SoftLength = v
+		case mfs.QUOTA_FLAG_SSIZE:
+			q.SoftSize = v
+		case mfs.QUOTA_FLAG_SREALSIZE:
+			q.SoftRealSize = v
+		case mfs.QUOTA_FLAG_HINODES:
+			q.HardInodes = uint32(v)
+		case mfs.QUOTA_FLAG_HLENGTH:
+			q.HardLength = v
+		case mfs.QUOTA_FLAG_HSIZE:
+			q.HardSize = v
+		case mfs.QUOTA_FLAG_HREALSIZE:
+			q.HardRealSize = v
+		}
+	}
+	if q.Flags == 0 {
+		err = fmt.Errorf("no limit is given")
+	}
+	return
+}
+
+func printQuota(q *mfs.Quota) {
+	bytes := func(b uint64) string {
+		return mfs.FormatBytes(float64(b), mfs.Binary)
+	}
+	fmt.Printf("%s: %s", q.Path, mfs.QuotaFlagsStr(q.Flags))
+	if q.Exceeded {
+		fmt.Printf(" exceeded")
+	}
+	fmt.Printf("\n\tinodes   %d soft %d hard %d\n", q.CurrInodes,
+		q.SoftInodes, q.HardInodes)
+	fmt.Printf("\tlength   %s soft %s hard %s\n", bytes(q.CurrLength),
+		bytes(q.SoftLength), bytes(q.HardLength))
+	fmt.Printf("\tsize     %s soft %s hard %s\n", bytes(q.CurrSize),
+		bytes(q.SoftSize), bytes(q.HardSize))
+	fmt.Printf("\trealsize %s soft %s hard %s\n", bytes(q.CurrRealSize),
+		bytes(q.SoftRealSize), bytes(q.HardRealSize))
+}
+
+func (s *quotaCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if err := parseAfterAction(f); err != nil {
+		return subcommands.ExitUsageError
+	}
+	if f.NArg() < 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	cmd := f.Arg(0)
+	switch {
+	case cmd == "report" && f.NArg() == 1:
+	case (cmd == "get" || cmd == "set") && f.NArg() == 2:
+	case cmd == "del" && (f.NArg() == 2 || f.NArg() == 3):
+	default:
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	var q *mfs.Quota
+	var flags uint8
+	var err error
+	switch cmd {
+	case "set":
+		q, err = s.quota()
+	case "del":
+		flags, err = mfs.ParseQuotaFlags(f.Arg(2))
+	}
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	quotas := make([]*mfs.Quota, 0)
+	switch cmd {
+	case "get":
+		q, err = c.GetQuota(f.Arg(1))
+	case "set":
+		q, err = c.SetQuota(f.Arg(1), q)
+	case "del":
+		q, err = c.DeleteQuota(f.Arg(1), flags)
+	case "report":
+		quotas, err = c.Quotas()
+	}
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	if q != nil {
+		quotas = append(quotas, q)
+	}
+	if s.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if cmd == "report" {
+			err = enc.Encode(quotas)
+		} else {
+			err = enc.Encode(q)
+		}
+		if err != nil {
+			glog.Error(err)
+			return subcommands.ExitFailure
+		}
+		return subcommands.ExitSuccess
+	}
+	for _, q := range quotas {
+		printQuota(q)
+	}
+	return subcommands.ExitSuccess
+}
+
+type exporterCmd struct {
+	listen   string
+	interval time.Duration
+}
+
+func (*exporterCmd) Name() string     { return "exporter" }
+func (*exporterCmd) Synopsis() string { return "serve metrics of mfsmaster for prometheus" }
+func (s *exporterCmd) Usage() string {
+	return fmt.Sprintf("%s [-l addr] [-i interval]\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *exporterCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.listen, "l", ":9429", "listen address")
+	f.DurationVar(&s.interval, "i", exporter.DEFAULT_INTERVAL, "refresh interval")
+}
+func (s *exporterCmd) Execute(ctx context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	e := exporter.New(c, s.interval)
+	go e.Run(ctx)
+	http.Handle("/metrics", e)
+	glog.Infof("serve metrics on %s/metrics", s.listen)
+	if err = http.ListenAndServe(s.listen, nil); err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type quotaWatchCmd struct {
+	warning, critical float64
+	interval, repeat  time.Duration
+	webhook, exec     string
+}
+
+func (*quotaWatchCmd) Name() string     { return "quota-watch" }
+func (*quotaWatchCmd) Synopsis() string { return "alert when quota usage crosses thresholds" }
+func (s *quotaWatchCmd) Usage() string {
+	return fmt.Sprintf("%s [-w ratio] [-c ratio] [-i interval] [-repeat interval] "+
+		"[-webhook url] [-exec command]\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *quotaWatchCmd) SetFlags(f *flag.FlagSet) {
+	f.Float64Var(&s.warning, "w", quotawatch.DEFAULT_WARNING, "warning ratio of soft quota")
+	f.Float64Var(&s.critical, "c", quotawatch.DEFAULT_CRITICAL, "critical ratio of soft quota")
+	f.DurationVar(&s.interval, "i", quotawatch.DEFAULT_INTERVAL, "poll interval")
+	f.DurationVar(&s.repeat, "repeat", 0, "alert the same level again after, 0 means never")
+	f.StringVar(&s.webhook, "webhook", "", "url to POST alerts in json")
+	f.StringVar(&s.exec, "exec", "", "command run by sh for alerts, json on stdin")
+}
+func (s *quotaWatchCmd) Execute(ctx context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 || s.warning <= 0 || s.critical < s.warning {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	actions := []quotawatch.Action{quotawatch.LogAction{}}
+	if len(s.webhook) > 0 {
+		actions = append(actions, &quotawatch.WebhookAction{URL: s.webhook})
+	}
+	if len(s.exec) > 0 {
+		actions = append(actions, &quotawatch.ExecAction{Command: s.exec})
+	}
+	w := quotawatch.New(c.Quotas, actions...)
+	w.Warning = s.warning
+	w.Critical = s.critical
+	w.Interval = s.interval
+	w.Repeat = s.repeat
+	w.Run(ctx)
+	return subcommands.ExitSuccess
+}
+
+type ipathCmd struct{}
+
+func (*ipathCmd) Name() string     { return "ipath" }
+func (*ipathCmd) Synopsis() string { return "show all paths of inodes" }
+func (s *ipathCmd) Usage() string {
+	return fmt.Sprintf("%s <inode> [inode...]\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *ipathCmd) SetFlags(f *flag.FlagSet) {}
+func (s *ipathCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() < 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	inodes := make([]uint32, 0, f.NArg())
+	for _, arg := range f.Args() {
+		inode, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil {
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+		inodes = append(inodes, uint32(inode))
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	status := subcommands.ExitSuccess
+	for _, inode := range inodes {
+		paths, err := c.InodePaths(inode)
+		if err != nil {
+			glog.Errorf("inode %d: %v", inode, err)
+			status = subcommands.ExitFailure
+			continue
+		}
+		for _, p := range paths {
+			fmt.Printf("%d: %s\n", inode, p)
+		}
+	}
+	return status
+}
+
+type eattrCmd struct {
+	recursive bool
+}
+
+func (*eattrCmd) Name() string     { return "eattr" }
+func (*eattrCmd) Synopsis() string { return "get, set, add or delete extra attributes" }
+func (s *eattrCmd) Usage() string {
+	return fmt.Sprintf("%s get [-r] <path>\n"+
+		"%s set|add|del [-r] <path> <eattrs>\n"+
+		"\teattrs: comma separated noowner,noattrcache,noentrycache,nodatacache,"+
+		"snapshot,undeletable,appendonly,immutable\n\t%s\n",
+		s.Name(), s.Name(), s.Synopsis())
+}
+func (s *eattrCmd) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&s.recursive, "r", false, "recursive")
+}
+func (s *eattrCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if err := parseAfterAction(f); err != nil {
+		return subcommands.ExitUsageError
+	}
+	smodes := map[string]uint8{
+		"set": mfs.SMODE_SET,
+		"add": mfs.SMODE_INCREASE,
+		"del": mfs.SMODE_DECREASE,
+	}
+	smode, set := smodes[f.Arg(0)]
+	var eattr mfs.EAttr
+	var err error
+	switch {
+	case f.NArg() == 2 && f.Arg(0) == "get":
+	case f.NArg() == 3 && set:
+		eattr, err = mfs.ParseEAttr(f.Arg(2))
+		if err != nil {
+			glog.Error(err)
+			return subcommands.ExitUsageError
+		}
+	default:
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	path := f.Arg(1)
+	if f.Arg(0) == "get" {
+		if s.recursive {
+			var st *mfs.EAttrStats
+			st, err = c.GetEAttrRecursive(path)
+			if err == nil {
+				fmt.Printf("%s:\n%s", path, st)
+			}
+		} else {
+			eattr, err = c.GetEAttr(path)
+			if err == nil {
+				fmt.Printf("%s: %s\n", path, eattr)
+			}
+		}
+	} else {
+		if s.recursive {
+			smode |= mfs.SMODE_RMASK
+		}
+		var ci *mfs.ChangeInfo
+		ci, err = c.SetEAttr(path, eattr, smode)
+		if err == nil {
+			fmt.Printf("%s: changed %d not changed %d not permitted %d\n", path,
+				ci.Changed, ci.NotChanged, ci.NotPermitted)
+		}
+	}
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type archiveCmd struct{}
+
+func (*archiveCmd) Name() string     { return "archive" }
+func (*archiveCmd) Synopsis() string { return "check, set or clear archive flag of chunks" }
+func (s *archiveCmd) Usage() string {
+	return fmt.Sprintf("%s check|set|clear <path>\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *archiveCmd) SetFlags(f *flag.FlagSet) {}
+func (s *archiveCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 2 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	switch f.Arg(0) {
+	case "check", "set", "clear":
+	default:
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	c, err := newClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer c.Close()
+	path := f.Arg(1)
+	if f.Arg(0) == "check" {
+		var ai *mfs.ArchiveInfo
+		ai, err = c.ArchiveStatus(path)
+		if err == nil {
+			fmt.Printf("%s:\n chunks archived: %d not archived: %d\n"+
+				" files archived: %d partially archived: %d not archived: %d\n",
+				path, ai.ArchChunks, ai.NotArchChunks, ai.ArchInodes,
+				ai.PartialInodes, ai.NotArchInodes)
+		}
+	} else {
+		var ac *mfs.ArchiveChange
+		if f.Arg(0) == "set" {
+			ac, err = c.SetArchive(path)
+		} else {
+			ac, err = c.ClearArchive(path)
+		}
+		if err == nil {
+			fmt.Printf("%s: chunks changed %d not changed %d files not permitted %d\n",
+				path, ac.ChunksChanged, ac.ChunksNotChanged, ac.InodesNotPermitted)
+		}
+	}
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+type sustainedCmd struct{}
+
+func (*sustainedCmd) Name() string     { return "sustained" }
+func (*sustainedCmd) Synopsis() string { return "list removed files which are still open" }
+func (s *sustainedCmd) Usage() string {
+	return fmt.Sprintf("%s ls\n\t%s\n", s.Name(), s.Synopsis())
+}
+func (s *sustainedCmd) SetFlags(f *flag.FlagSet) {}
+func (s *sustainedCmd) Execute(_ context.Context, f *flag.FlagSet,
+	_ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 || f.Arg(0) != "ls" {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	m, err := newMetaClient()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	defer m.Close()
+	entries, err := m.Sustained()
+	if err != nil {
+		glog.Error(err)
+		return subcommands.ExitFailure
+	}
+	var total uint64
+	for _, e := range entries {
+		total += e.Info.Size
+		fmt.Printf("%d\t%s\t%s\n", e.Inode,
+			mfs.FormatBytes(float64(e.Info.Size), mfs.Binary), e.Name)
+	}
+	fmt.Printf("sustained files: %d size: %s\n", len(entries),
+		mfs.FormatBytes(float64(total), mfs.Binary))
+	return subcommands.ExitSuccess
+}
+
+// flags > env > profile > mfsmount.cfg > defaults
+func newClient() (*mfs.Client, error) {
+	cfg, err := mfs.LoadConfig(&flagConfig, profile)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.NewClient()
+}
+
+func newMetaClient() (*mfs.MetaClient, error) {
+	cfg, err := mfs.LoadConfig(&flagConfig, profile)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.NewMetaClient()
+}
+
 func mainRecover() {
 	if err := recover(); err != nil {
 		glog.Fatal("Error: ", err)
@@ -262,6 +1306,22 @@ func main() {
 	subcommands.Register(&removeCmd{}, "mfs")
 	subcommands.Register(&mkdirCmd{}, "mfs")
 	subcommands.Register(&rmdirCmd{}, "mfs")
+	subcommands.Register(&xattrCmd{}, "mfs")
+	subcommands.Register(&snapshotCmd{}, "mfs")
+	subcommands.Register(&fileinfoCmd{}, "mfs")
+	subcommands.Register(&checkfileCmd{}, "mfs")
+	subcommands.Register(&verifyCmd{}, "mfs")
+	subcommands.Register(&scrubCmd{}, "mfs")
+	subcommands.Register(&undergoalCmd{}, "mfs")
+	subcommands.Register(&infoCmd{}, "mfs")
+	subcommands.Register(&sessionsCmd{}, "mfs")
+	subcommands.Register(&quotaCmd{}, "mfs")
+	subcommands.Register(&exporterCmd{}, "mfs")
+	subcommands.Register(&quotaWatchCmd{}, "mfs")
+	subcommands.Register(&ipathCmd{}, "mfs")
+	subcommands.Register(&eattrCmd{}, "mfs")
+	subcommands.Register(&archiveCmd{}, "mfs")
+	subcommands.Register(&sustainedCmd{}, "mfs")
 
 	flag.Parse()
 	if version {
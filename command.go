@@ -6,7 +6,10 @@ MIT License
 Copyright (c) 2019 DHacky
 */
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 const TCP_RETRY_TIMES = 3
 const TCP_CONNECT_TIMEOUT = 30 * time.Second
@@ -618,6 +621,14 @@ const CLTOMA_INFO = 510
 // laststore_duration:32 laststore_status:8 state:8 nstate:8 stable:8 sync:8
 // leaderip:32 state_chg_time:32 meta_version:64 (size = 121,version >= 2.0.0)
 
+const CLTOMA_CHUNKS_MATRIX = 516
+
+// CLTOMA:
+// matrixid:8
+// matrixid = 0 all chunks, 1 chunks without copies marked for removal
+// MATOCL:
+// 11 * [ 11 * [ chunks:32 ] ] - goal by valid copies, 10 means 10 or more
+
 const CLTOMA_QUOTA_INFO = 518
 
 // MATOCL:
@@ -632,6 +643,70 @@ const CLTOMA_SESSION_COMMAND = 526
 // commandid:8 sessionid:32
 // commandid = 0 remove session
 
+// status code from mfsmaster, index of ERROR_TABLE
+type MFSError uint8
+
+const (
+	MFS_STATUS_OK MFSError = iota
+	MFS_ERROR_EPERM
+	MFS_ERROR_ENOTDIR
+	MFS_ERROR_ENOENT
+	MFS_ERROR_EACCES
+	MFS_ERROR_EEXIST
+	MFS_ERROR_EINVAL
+	MFS_ERROR_ENOTEMPTY
+	MFS_ERROR_CHUNKLOST
+	MFS_ERROR_OUTOFMEMORY
+	MFS_ERROR_INDEXTOOBIG
+	MFS_ERROR_LOCKED
+	MFS_ERROR_NOCHUNKSERVERS
+	MFS_ERROR_NOCHUNK
+	MFS_ERROR_CHUNKBUSY
+	MFS_ERROR_REGISTER
+	MFS_ERROR_NOTDONE
+	MFS_ERROR_NOTOPENED
+	MFS_ERROR_NOTSTARTED
+	MFS_ERROR_WRONGVERSION
+	MFS_ERROR_CHUNKEXIST
+	MFS_ERROR_NOSPACE
+	MFS_ERROR_IO
+	MFS_ERROR_BNUMTOOBIG
+	MFS_ERROR_WRONGSIZE
+	MFS_ERROR_WRONGOFFSET
+	MFS_ERROR_CANTCONNECT
+	MFS_ERROR_WRONGCHUNKID
+	MFS_ERROR_DISCONNECTED
+	MFS_ERROR_CRC
+	MFS_ERROR_DELAYED
+	MFS_ERROR_CANTCREATEPATH
+	MFS_ERROR_MISMATCH
+	MFS_ERROR_EROFS
+	MFS_ERROR_QUOTA
+	MFS_ERROR_BADSESSIONID
+	MFS_ERROR_NOPASSWORD
+	MFS_ERROR_BADPASSWORD
+	MFS_ERROR_ENOATTR
+	MFS_ERROR_ENOTSUP
+	MFS_ERROR_ERANGE
+	MFS_ERROR_NOTFOUND
+	MFS_ERROR_ACTIVE
+	MFS_ERROR_CSNOTPRESENT
+	MFS_ERROR_WAITING
+	MFS_ERROR_EAGAIN
+	MFS_ERROR_EINTR
+	MFS_ERROR_ECANCELED
+	MFS_ERROR_ENOENT_NOCACHE
+	MFS_ERROR_EPERM_NOTADMIN
+	MFS_ERROR_CLASSEXISTS
+	MFS_ERROR_CLASSLIMITREACH
+	MFS_ERROR_NOSUCHCLASS
+	MFS_ERROR_CLASSINUSE
+)
+
+func (e MFSError) Error() string {
+	return fmt.Sprintf("got error from mfsmaster: %s", MFSStrerror(uint8(e)))
+}
+
 var ERROR_TABLE = []string{
 	"OK",
 	"Operation not permitted",
@@ -0,0 +1,275 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	DEFAULT_MOUNT_CONFIG = "/etc/mfs/mfsmount.cfg"
+	DEFAULT_MASTER       = "mfsmaster"
+	DEFAULT_MASTER_PORT  = "9421"
+	DEFAULT_PROFILE      = "default"
+)
+
+// environment variables of Config, see EnvConfig
+const (
+	ENV_MASTER    = "MFS_MASTER"
+	ENV_PORT      = "MFS_PORT"
+	ENV_SUBFOLDER = "MFS_SUBFOLDER"
+	ENV_PASSWORD  = "MFS_PASSWORD"
+	ENV_MD5PASS   = "MFS_MD5PASS"
+	ENV_PASSFILE  = "MFS_PASSFILE"
+	ENV_PROFILE   = "MFS_PROFILE"
+)
+
+// options of mfsmount.cfg, empty means not set
+type Config struct {
+	Master    string // mfsmaster, host or host:port
+	Port      string // mfsport
+	Subfolder string // mfssubfolder
+	Password  string // mfspassword
+	MD5Pass   string // mfsmd5pass
+	PassFile  string // mfspassfile, the first line is the password
+}
+
+func (cfg *Config) set(key, value string) bool {
+	switch key {
+	case "mfsmaster":
+		cfg.Master = value
+	case "mfsport":
+		cfg.Port = value
+	case "mfssubfolder":
+		cfg.Subfolder = value
+	case "mfspassword":
+		cfg.Password = value
+	case "mfsmd5pass":
+		cfg.MD5Pass = value
+	case "mfspassfile":
+		cfg.PassFile = value
+	default:
+		return false
+	}
+	return true
+}
+
+func (cfg *Config) hasPassword() bool {
+	return len(cfg.Password) > 0 || len(cfg.MD5Pass) > 0 || len(cfg.PassFile) > 0
+}
+
+// fill the empty options of cfg from o, the password options are taken
+// together so a lower layer can not override the kind of password
+func (cfg *Config) merge(o *Config) {
+	if o == nil {
+		return
+	}
+	if len(cfg.Master) == 0 {
+		cfg.Master = o.Master
+	}
+	if len(cfg.Port) == 0 {
+		cfg.Port = o.Port
+	}
+	if len(cfg.Subfolder) == 0 {
+		cfg.Subfolder = o.Subfolder
+	}
+	if !cfg.hasPassword() {
+		cfg.Password, cfg.MD5Pass, cfg.PassFile = o.Password, o.MD5Pass, o.PassFile
+	}
+}
+
+// address of mfsmaster, mfsport is ignored if mfsmaster has a port
+func (cfg *Config) Addr() string {
+	master, port := cfg.Master, cfg.Port
+	if len(master) == 0 {
+		master = DEFAULT_MASTER
+	}
+	if _, _, err := net.SplitHostPort(master); err == nil {
+		return master
+	}
+	if len(port) == 0 {
+		port = DEFAULT_MASTER_PORT
+	}
+	return net.JoinHostPort(master, port)
+}
+
+// MAClient with the password of cfg, mfspassword first,
+// then mfsmd5pass and mfspassfile
+func (cfg *Config) maclient() (mc *MAClient, err error) {
+	password, md5pass := cfg.Password, ""
+	switch {
+	case len(password) > 0:
+	case len(cfg.MD5Pass) > 0:
+		md5pass = cfg.MD5Pass
+		if _, err = ParseMD5Pass(md5pass); err != nil {
+			return
+		}
+	case len(cfg.PassFile) > 0:
+		if password, err = ReadPasswordFile(cfg.PassFile); err != nil {
+			return
+		}
+	}
+	mc = NewMAClientPwd(cfg.Addr(), password, true)
+	mc.MD5Pass = md5pass
+	return
+}
+
+func (cfg *Config) NewClient() (c *Client, err error) {
+	mc, err := cfg.maclient()
+	if err != nil {
+		return
+	}
+	return newClient(mc, cfg.Subfolder)
+}
+
+func (cfg *Config) NewMetaClient() (m *MetaClient, err error) {
+	mc, err := cfg.maclient()
+	if err != nil {
+		return
+	}
+	return newMetaClient(mc)
+}
+
+// options of mfsmount.cfg style files, before any [name] they belong to
+// the profile "", options are separated by commas or lines, comments
+// start with #, unknown mount options and the mount point are ignored
+func ParseConfig(r io.Reader) (profiles map[string]*Config, err error) {
+	profiles = map[string]*Config{"": new(Config)}
+	cfg := profiles[""]
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "/") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || len(line) < 3 {
+				err = fmt.Errorf("line %d: bad profile %q", n, line)
+				return
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if profiles[name] == nil {
+				profiles[name] = new(Config)
+			}
+			cfg = profiles[name]
+			continue
+		}
+		for _, opt := range strings.Split(line, ",") {
+			kv := strings.SplitN(strings.TrimSpace(opt), "=", 2)
+			if len(kv) == 2 {
+				cfg.set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+			}
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+// profiles of file, no profiles if the file does not exist
+func LoadProfiles(path string) (profiles map[string]*Config, err error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return map[string]*Config{"": new(Config)}, nil
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	profiles, err = ParseConfig(f)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+	}
+	return
+}
+
+// options of mfsmount.cfg
+func LoadMountConfig(path string) (cfg *Config, err error) {
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		return
+	}
+	return profiles[""], nil
+}
+
+// ~/.config/mfscli/profiles
+func ProfilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "mfscli", "profiles")
+}
+
+func EnvConfig() *Config {
+	return &Config{
+		Master:    os.Getenv(ENV_MASTER),
+		Port:      os.Getenv(ENV_PORT),
+		Subfolder: os.Getenv(ENV_SUBFOLDER),
+		Password:  os.Getenv(ENV_PASSWORD),
+		MD5Pass:   os.Getenv(ENV_MD5PASS),
+		PassFile:  os.Getenv(ENV_PASSFILE),
+	}
+}
+
+// names of profiles in file, for usage and errors
+func profileNames(profiles map[string]*Config) (names []string) {
+	for name := range profiles {
+		if len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// the options of flags, then environment, then profile of ProfilePath
+// and the options before any profile, then DEFAULT_MOUNT_CONFIG, profile is MFS_PROFILE or DEFAULT_PROFILE
+// if empty, an unknown profile is an error unless it is the default
+func LoadConfig(flags *Config, profile string) (cfg *Config, err error) {
+	return loadConfig(flags, profile, ProfilePath(), DEFAULT_MOUNT_CONFIG)
+}
+
+func loadConfig(flags *Config, profile, profilePath,
+	mountPath string) (cfg *Config, err error) {
+	cfg = new(Config)
+	cfg.merge(flags)
+	cfg.merge(EnvConfig())
+	if len(profile) == 0 {
+		profile = os.Getenv(ENV_PROFILE)
+	}
+	profiles, err := LoadProfiles(profilePath)
+	if err != nil {
+		return
+	}
+	if len(profile) == 0 {
+		profile = DEFAULT_PROFILE
+	}
+	if profiles[profile] == nil && profile != DEFAULT_PROFILE {
+		err = fmt.Errorf("profile %s not found in %s, profiles: %s", profile,
+			profilePath, strings.Join(profileNames(profiles), " "))
+		return
+	}
+	cfg.merge(profiles[profile])
+	cfg.merge(profiles[""])
+	mount, err := LoadMountConfig(mountPath)
+	if err != nil {
+		return
+	}
+	cfg.merge(mount)
+	return
+}
@@ -0,0 +1,140 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMountConfig = `# mfsmount.cfg
+mfsmaster=master.local, mfsport=9521
+nosuid,nodev
+mfssubfolder=/data
+/mnt/mfs
+`
+
+const testProfiles = `mfsmd5pass=5ebe2294ecd0e0f08eab7690d2a6ee69
+
+[prod]
+mfsmaster=prod:9421 # with port
+mfspassword=secret
+[dev]
+mfsmaster=dev
+`
+
+func TestParseConfig(t *testing.T) {
+	profiles, err := ParseConfig(strings.NewReader(testMountConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := profiles[""]
+	if cfg.Master != "master.local" || cfg.Port != "9521" || cfg.Subfolder != "/data" {
+		t.Error("unexpect", cfg)
+	}
+	if addr := cfg.Addr(); addr != "master.local:9521" {
+		t.Error("unexpect", addr)
+	}
+	profiles, err = ParseConfig(strings.NewReader(testProfiles))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 3 || profiles["prod"].Addr() != "prod:9421" ||
+		profiles["dev"].Addr() != "dev:9421" || profiles["prod"].Password != "secret" {
+		t.Error("unexpect", profiles)
+	}
+	if _, err = ParseConfig(strings.NewReader("[bad\n")); err == nil {
+		t.Error("expect error")
+	}
+	if addr := new(Config).Addr(); addr != "mfsmaster:9421" {
+		t.Error("unexpect", addr)
+	}
+}
+
+// set an environment variable, the returned func restores it
+func setenv(key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mountPath := filepath.Join(dir, "mfsmount.cfg")
+	profilePath := filepath.Join(dir, "profiles")
+	ioutil.WriteFile(mountPath, []byte(testMountConfig), 0644)
+	ioutil.WriteFile(profilePath, []byte(testProfiles), 0600)
+	for _, env := range []string{ENV_MASTER, ENV_PORT, ENV_SUBFOLDER,
+		ENV_PASSWORD, ENV_MD5PASS, ENV_PASSFILE, ENV_PROFILE} {
+		defer setenv(env, "")()
+	}
+	// mfsmount.cfg and the common options of profiles
+	cfg, err := loadConfig(nil, "", profilePath, mountPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr() != "master.local:9521" || cfg.Subfolder != "/data" ||
+		len(cfg.MD5Pass) == 0 {
+		t.Error("unexpect", cfg)
+	}
+	// profile over mfsmount.cfg, the password of profile as a whole
+	cfg, _ = loadConfig(nil, "prod", profilePath, mountPath)
+	if cfg.Addr() != "prod:9421" || cfg.Password != "secret" || cfg.MD5Pass != "" {
+		t.Error("unexpect", cfg)
+	}
+	// env over profile
+	os.Setenv(ENV_PROFILE, "dev")
+	os.Setenv(ENV_MASTER, "env")
+	cfg, _ = loadConfig(nil, "", profilePath, mountPath)
+	if cfg.Addr() != "env:9521" {
+		t.Error("unexpect", cfg)
+	}
+	// flags over env
+	cfg, _ = loadConfig(&Config{Master: "flag:1", PassFile: "/pass"}, "",
+		profilePath, mountPath)
+	if cfg.Addr() != "flag:1" || cfg.PassFile != "/pass" || cfg.MD5Pass != "" {
+		t.Error("unexpect", cfg)
+	}
+	if _, err = loadConfig(nil, "none", profilePath, mountPath); err == nil {
+		t.Error("expect error of unknown profile")
+	}
+	// missing files are empty
+	os.Setenv(ENV_PROFILE, "")
+	cfg, err = loadConfig(nil, "", filepath.Join(dir, "no"), filepath.Join(dir, "no"))
+	if err != nil || cfg.Addr() != "env:9421" {
+		t.Error("unexpect", cfg, err)
+	}
+	// the default profile may be named without a section
+	for _, profile := range []string{DEFAULT_PROFILE, ""} {
+		os.Setenv(ENV_PROFILE, profile)
+		cfg, err = loadConfig(nil, DEFAULT_PROFILE, profilePath, mountPath)
+		if err != nil || cfg.Addr() != "env:9521" {
+			t.Error("unexpect", cfg, err)
+		}
+		cfg, err = loadConfig(nil, "", profilePath, mountPath)
+		if err != nil || cfg.Addr() != "env:9521" {
+			t.Error("unexpect", cfg, err)
+		}
+	}
+	cfg = &Config{MD5Pass: "bad"}
+	if _, err = cfg.NewClient(); err == nil {
+		t.Error("expect error of md5pass")
+	}
+}
@@ -0,0 +1,312 @@
+package coord
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+// named mutexes and leader election leases stored as files
+// in a mfs directory, built on the posix locks of mfsmaster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mfs "github.com/Hacky-DH/moosefs-client"
+	"github.com/golang/glog"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DEFAULT_TTL = 15 * time.Second
+
+// xattrs of lock files
+const (
+	XATTR_FENCE   = "user.mfs.fence"   // fencing token counter
+	XATTR_HOLDER  = "user.mfs.holder"  // id of the current holder
+	XATTR_RENEWED = "user.mfs.renewed" // unix time of the last renewal
+)
+
+var ErrNotHeld = errors.New("lock is not held")
+var ErrLeaseLost = errors.New("lease is lost")
+
+type Coordinator struct {
+	client *mfs.Client
+	dir    string
+	ttl    time.Duration
+	Id     string // holder id, hostname:pid by default
+}
+
+// dir is created if not exists
+func New(c *mfs.Client, dir string, ttl time.Duration) (co *Coordinator, err error) {
+	if err = c.Mkdir(dir); err != nil {
+		return
+	}
+	if ttl <= 0 {
+		ttl = DEFAULT_TTL
+	}
+	host, _ := os.Hostname()
+	co = &Coordinator{
+		client: c,
+		dir:    dir,
+		ttl:    ttl,
+		Id:     fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+	return
+}
+
+// a held lock on a file renewed in background
+type held struct {
+	file  *mfs.File
+	owner uint64
+	token uint64
+	stop  chan struct{}
+	lost  chan struct{}
+	done  sync.WaitGroup
+}
+
+type Mutex struct {
+	co   *Coordinator
+	name string
+	mu   sync.Mutex
+	h    *held
+}
+
+func (co *Coordinator) NewMutex(name string) *Mutex {
+	return &Mutex{co: co, name: name}
+}
+
+func (co *Coordinator) path(name string) string {
+	return filepath.Join(co.dir, name)
+}
+
+// take the lock with try, then bump the fencing token
+func (co *Coordinator) acquire(name string,
+	try func(f *mfs.File, owner uint64) error) (h *held, err error) {
+	f, err := co.client.OpenOrCreate(co.path(name))
+	if err != nil {
+		return
+	}
+	owner := uint64(rand.Int63())
+	if err = try(f, owner); err != nil {
+		f.Close()
+		return
+	}
+	h = &held{
+		file:  f,
+		owner: owner,
+		stop:  make(chan struct{}),
+		lost:  make(chan struct{}),
+	}
+	if err = co.fence(h); err != nil {
+		f.Close()
+		h = nil
+		return
+	}
+	h.done.Add(1)
+	go co.renew(h)
+	glog.V(5).Infof("coord acquire %s token %d", name, h.token)
+	return
+}
+
+func (co *Coordinator) fence(h *held) (err error) {
+	var token uint64
+	v, err := h.file.Getxattr(XATTR_FENCE)
+	if err == nil {
+		token, err = strconv.ParseUint(string(v), 10, 64)
+		if err != nil {
+			return
+		}
+	} else if err != mfs.MFS_ERROR_ENOATTR {
+		return
+	}
+	token++
+	err = h.file.Setxattr(XATTR_FENCE, []byte(strconv.FormatUint(token, 10)),
+		mfs.XATTR_CREATE_OR_REPLACE)
+	if err != nil {
+		return
+	}
+	err = h.file.Setxattr(XATTR_HOLDER, []byte(co.Id), mfs.XATTR_CREATE_OR_REPLACE)
+	if err != nil {
+		return
+	}
+	if err = co.touch(h); err != nil {
+		return
+	}
+	h.token = token
+	return
+}
+
+// re-take the lock which is a no-op for the same owner, fails if the
+// session was lost and another owner got the lock meanwhile
+func (co *Coordinator) touch(h *held) (err error) {
+	err = h.file.TryLock(h.owner, mfs.POSIX_LOCK_WRLCK, 0, 0)
+	if err != nil {
+		return
+	}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	return h.file.Setxattr(XATTR_RENEWED, []byte(now), mfs.XATTR_CREATE_OR_REPLACE)
+}
+
+// the lease is lost if the lock is taken by another owner or
+// no renewal succeeds within ttl, e.g. reconnect to mfsmaster fails
+func (co *Coordinator) renew(h *held) {
+	defer h.done.Done()
+	ticker := time.NewTicker(co.ttl / 3)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			err := co.touch(h)
+			if err == nil {
+				last = time.Now()
+				continue
+			}
+			glog.V(5).Infof("coord renew %s error %v", h.file.Path, err)
+			if err == mfs.ErrWouldBlock || time.Since(last) >= co.ttl {
+				close(h.lost)
+				return
+			}
+		}
+	}
+}
+
+// xattrs of a lock file used by clearHolder
+type holderFile interface {
+	Getxattr(name string) ([]byte, error)
+	Removexattr(name string) error
+}
+
+// remove the holder record of id, a lost lock may be held by another
+// coordinator which wrote its own id, so it is left untouched
+func clearHolder(f holderFile, id string, lost <-chan struct{}) error {
+	select {
+	case <-lost:
+		return ErrLeaseLost
+	default:
+	}
+	v, err := f.Getxattr(XATTR_HOLDER)
+	if err == nil && string(v) == id {
+		f.Removexattr(XATTR_HOLDER)
+	}
+	return nil
+}
+
+func (co *Coordinator) release(h *held) (err error) {
+	close(h.stop)
+	h.done.Wait()
+	err = clearHolder(h.file, co.Id, h.lost)
+	if e := h.file.Close(); e != nil {
+		err = e
+	}
+	return
+}
+
+func tryLock(f *mfs.File, owner uint64) error {
+	return f.TryLock(owner, mfs.POSIX_LOCK_WRLCK, 0, 0)
+}
+
+// wait until the mutex is acquired or ctx is done, return the fencing token
+func (m *Mutex) Lock(ctx context.Context) (token uint64, err error) {
+	return m.lock(func(f *mfs.File, owner uint64) error {
+		return f.Lock(ctx, owner, mfs.POSIX_LOCK_WRLCK, 0, 0)
+	})
+}
+
+// return mfs.ErrWouldBlock if the mutex is held by others
+func (m *Mutex) TryLock() (token uint64, err error) {
+	return m.lock(tryLock)
+}
+
+func (m *Mutex) lock(try func(*mfs.File, uint64) error) (token uint64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.h != nil {
+		err = fmt.Errorf("mutex %s is already held", m.name)
+		return
+	}
+	h, err := m.co.acquire(m.name, try)
+	if err != nil {
+		return
+	}
+	m.h = h
+	token = h.token
+	return
+}
+
+// return ErrLeaseLost if the mutex was lost before unlock
+func (m *Mutex) Unlock() (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.h == nil {
+		err = ErrNotHeld
+		return
+	}
+	err = m.co.release(m.h)
+	m.h = nil
+	return
+}
+
+// closed when the held mutex is lost, nil if not held
+func (m *Mutex) Lost() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.h == nil {
+		return nil
+	}
+	return m.h.lost
+}
+
+// leader election lease
+type Lease struct {
+	Mutex
+}
+
+func (co *Coordinator) NewLease(name string) *Lease {
+	return &Lease{Mutex{co: co, name: name}}
+}
+
+// wait until becoming the leader or ctx is done, return the fencing token
+func (l *Lease) Campaign(ctx context.Context) (token uint64, err error) {
+	return l.Lock(ctx)
+}
+
+func (l *Lease) Resign() error {
+	return l.Unlock()
+}
+
+// current leader id, empty if no leader renewed within ttl
+func (l *Lease) Leader() (id string, err error) {
+	c := l.co.client
+	p := l.co.path(l.name)
+	v, err := c.Getxattr(p, XATTR_RENEWED)
+	if err == mfs.MFS_ERROR_ENOATTR || err == mfs.MFS_ERROR_ENOENT {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	renewed, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		return
+	}
+	if time.Since(time.Unix(renewed, 0)) >= l.co.ttl {
+		return
+	}
+	v, err = c.Getxattr(p, XATTR_HOLDER)
+	if err == mfs.MFS_ERROR_ENOATTR {
+		err = nil
+		return
+	}
+	id = string(v)
+	return
+}
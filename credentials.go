@@ -0,0 +1,140 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"context"
+	"os"
+)
+
+// identity sent with the commands checking permissions
+type Credentials struct {
+	Uid  uint32
+	Gid  uint32   // primary group
+	Gids []uint32 // supplementary groups
+}
+
+// uid, gid and supplementary groups of the current process
+func ProcessCredentials() *Credentials {
+	cred := &Credentials{
+		Uid: uint32(os.Getuid()),
+		Gid: uint32(os.Getgid()),
+	}
+	groups, _ := os.Getgroups()
+	for _, g := range groups {
+		cred.Gids = append(cred.Gids, uint32(g))
+	}
+	return cred
+}
+
+// all groups, the primary group first and without duplicates
+func (cr *Credentials) Groups() []uint32 {
+	groups := []uint32{cr.Gid}
+	for _, g := range cr.Gids {
+		if g != cr.Gid {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// uid:gcnt:gids of the commands taking groups
+func (cr *Credentials) pack() []uint32 {
+	groups := cr.Groups()
+	ids := make([]uint32, 0, 2+len(groups))
+	ids = append(ids, cr.Uid, uint32(len(groups)))
+	return append(ids, groups...)
+}
+
+type credentialsKey struct{}
+
+// ctx carrying cred, see MAClient.WithContext and Client.WithContext
+func ContextWithCredentials(ctx context.Context, cred *Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, cred)
+}
+
+func CredentialsFromContext(ctx context.Context) (cred *Credentials, ok bool) {
+	cred, ok = ctx.Value(credentialsKey{}).(*Credentials)
+	return
+}
+
+// the identity of requests, the mapall identity of session takes
+// precedence over the credentials
+func (c *MAClient) identity() *Credentials {
+	if c.Params != nil && c.Params.MapAll() {
+		return &Credentials{Uid: c.Params.MapallUid, Gid: c.Params.MapallGid}
+	}
+	if c.cred == nil {
+		return ProcessCredentials()
+	}
+	return c.cred
+}
+
+func (c *MAClient) ids() []uint32 {
+	return c.identity().pack()
+}
+
+func (c *MAClient) Credentials() *Credentials {
+	return c.identity()
+}
+
+// set the default credentials of requests, nil means the process identity
+func (c *MAClient) SetCredentials(cred *Credentials) {
+	c.cred = cred
+}
+
+// a MAClient sharing the connection and session of c,
+// requests are sent with cred
+func (c *MAClient) WithCredentials(cred *Credentials) *MAClient {
+	return &MAClient{masterConn: c.masterConn, cred: cred}
+}
+
+// WithCredentials if ctx carries credentials, or c itself
+func (c *MAClient) WithContext(ctx context.Context) *MAClient {
+	if cred, ok := CredentialsFromContext(ctx); ok {
+		return c.WithCredentials(cred)
+	}
+	return c
+}
+
+func (c *Client) Credentials() *Credentials {
+	return c.mc.Credentials()
+}
+
+// set the default credentials of all requests of c
+func (c *Client) SetCredentials(cred *Credentials) {
+	c.mc.SetCredentials(cred)
+}
+
+// a Client sharing the session of c, acting as cred, closing it
+// does nothing, locks of its files are released when c is closed
+func (c *Client) WithCredentials(cred *Credentials) *Client {
+	root := c.owner()
+	return &Client{
+		mc:        c.mc.WithCredentials(cred),
+		Cwd:       c.Cwd,
+		currInode: c.currInode,
+		root:      root,
+	}
+}
+
+// WithCredentials if ctx carries credentials, or c itself,
+// c.WithContext(ContextWithCredentials(ctx, cred)).Mkdir(path)
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if cred, ok := CredentialsFromContext(ctx); ok {
+		return c.WithCredentials(cred)
+	}
+	return c
+}
+
+// the client owning the session, lock table and tools connection
+func (c *Client) owner() *Client {
+	if c.root != nil {
+		return c.root
+	}
+	return c
+}
@@ -0,0 +1,71 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCredentialsPack(t *testing.T) {
+	cred := &Credentials{Uid: 1000, Gid: 100, Gids: []uint32{10, 100, 20}}
+	if ids := cred.pack(); !reflect.DeepEqual(ids, []uint32{1000, 3, 100, 10, 20}) {
+		t.Error("unexpect", ids)
+	}
+	msg := PackCmd(CLTOMA_FUSE_ACCESS, uint32(0), uint32(1), cred.pack(), uint16(4))
+	if len(msg) != 8+4+4+4+4+12+2 {
+		t.Error("unexpect size", len(msg))
+	}
+	var uid, gcnt, gid uint32
+	UnPack(msg[16:], &uid, &gcnt, &gid)
+	if uid != 1000 || gcnt != 3 || gid != 100 {
+		t.Error("unexpect", uid, gcnt, gid)
+	}
+	cred = &Credentials{Uid: 1, Gid: 2}
+	if ids := cred.pack(); !reflect.DeepEqual(ids, []uint32{1, 1, 2}) {
+		t.Error("unexpect", ids)
+	}
+}
+
+func TestCredentialsOverride(t *testing.T) {
+	c := NewMAClientPwd("", "", false)
+	c.SetCredentials(&Credentials{Uid: 1, Gid: 2})
+	v := c.WithContext(ContextWithCredentials(context.Background(),
+		&Credentials{Uid: 3, Gid: 4, Gids: []uint32{5}}))
+	if v.masterConn != c.masterConn {
+		t.Error("view must share the connection")
+	}
+	if ids := v.ids(); !reflect.DeepEqual(ids, []uint32{3, 2, 4, 5}) {
+		t.Error("unexpect", ids)
+	}
+	if ids := c.ids(); !reflect.DeepEqual(ids, []uint32{1, 1, 2}) {
+		t.Error("unexpect", ids)
+	}
+	if c.WithContext(context.Background()) != c {
+		t.Error("expect the same client")
+	}
+	c.Params = &SessionParams{Flags: SESFLAG_MAPALL, MapallUid: 7, MapallGid: 8}
+	if ids := v.ids(); !reflect.DeepEqual(ids, []uint32{7, 1, 8}) {
+		t.Error("unexpect mapall", ids)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	v := c.WithCredentials(&Credentials{Uid: 65534, Gid: 65534})
+	defer v.Close()
+	if err := v.Mkdir("/cred_test_nobody"); err == nil {
+		t.Error("expect permission denied")
+		c.Rmdir("/cred_test_nobody")
+	}
+}
@@ -38,8 +38,7 @@ type CSItem struct {
 }
 
 func (t *CSItem) addr() string {
-	return fmt.Sprintf("%d.%d.%d.%d:%d", t.Ip>>24, 0xff&(t.Ip>>16),
-		0xff&(t.Ip>>8), 0xff&t.Ip, t.Port)
+	return ipPortStr(t.Ip, t.Port)
 }
 
 type CSItemMap map[uint32]*CSItem
@@ -231,27 +230,33 @@ func (d *CSData) WriteBlock(c *CSClient, wid uint32, blockNum, off uint16,
 		return
 	}
 	rbuf := make([]byte, 21)
-	var rcmd, size uint32 = ANTOAN_NOP, 4
-	for rcmd == ANTOAN_NOP && size == 4 {
-		n, e := c.Recv(rbuf)
-		if e != nil {
-			err = fmt.Errorf("recv from cs error %v", e)
-			return
+	var cid uint64
+	var wrid uint32
+	var status uint8
+	for {
+		var rcmd, size uint32 = ANTOAN_NOP, 4
+		for rcmd == ANTOAN_NOP && size == 4 {
+			n, e := c.Recv(rbuf)
+			if e != nil {
+				err = fmt.Errorf("recv from cs error %v", e)
+				return
+			}
+			if n < 21 {
+				err = fmt.Errorf("recv from cs size is too short")
+				return
+			}
+			UnPack(rbuf, &rcmd, &size)
 		}
-		if n < 21 {
-			err = fmt.Errorf("recv from cs size is too short")
+		if rcmd != CSTOCL_WRITE_STATUS {
+			err = fmt.Errorf("recv from cs bad command %d", rcmd)
 			return
 		}
-		UnPack(rbuf, &rcmd, &size)
-	}
-	if rcmd != CSTOCL_WRITE_STATUS {
-		err = fmt.Errorf("recv from cs bad command %d", rcmd)
-		return
+		UnPack(rbuf[8:], &cid, &wrid, &status)
+		// write id 0 is the status of CLTOCS_WRITE, the chain is ready
+		if wrid != 0 || wid == 0 || status != 0 {
+			break
+		}
 	}
-	var cid uint64
-	var wrid uint32
-	var status uint8
-	UnPack(rbuf[8:], &cid, &wrid, &status)
 	if status != 0 {
 		err = fmt.Errorf("write block %s", MFSStrerror(status))
 		return
@@ -395,3 +400,98 @@ func (d *CSData) ReadBlock(c *CSClient, buf []byte, off uint64) (n uint32, err e
 	}
 	return
 }
+
+// crc32 of every block in a chunk
+type ChecksumTab [MFSBLOCKSINCHUNK]uint32
+
+// recv the answer of cmd of at most maxsize bytes, skip nop,
+// the connection can not be used after an error
+func (c *CSClient) recvCmd(cmd, maxsize uint32) (buf []byte, err error) {
+	hdr := make([]byte, 8)
+	for {
+		if _, err = c.Recv(hdr); err != nil {
+			err = fmt.Errorf("recv from cs error %v", err)
+			return
+		}
+		var rcmd, size uint32
+		UnPack(hdr, &rcmd, &size)
+		if size > maxsize {
+			err = fmt.Errorf("recv from cs command %d size %d>%d", rcmd, size, maxsize)
+			return
+		}
+		buf = make([]byte, size)
+		if size > 0 {
+			if _, err = c.Recv(buf); err != nil {
+				err = fmt.Errorf("recv from cs error %v", err)
+				return
+			}
+		}
+		if rcmd == ANTOAN_NOP {
+			continue
+		}
+		if rcmd != cmd {
+			err = fmt.Errorf("recv from cs bad command %d", rcmd)
+		}
+		return
+	}
+}
+
+func (c *CSClient) checksumCmd(cmd, rcmd uint32, chunkId uint64, version uint32,
+	size int) (buf []byte, err error) {
+	msg := PackCmd(cmd, chunkId, version)
+	if err = c.Send(msg); err != nil {
+		err = fmt.Errorf("send checksum to cs error %v", err)
+		return
+	}
+	maxsize := uint32(13) // the status answer
+	if size > int(maxsize) {
+		maxsize = uint32(size)
+	}
+	buf, err = c.recvCmd(rcmd, maxsize)
+	if err != nil {
+		return
+	}
+	if len(buf) != 13 && len(buf) != size {
+		err = fmt.Errorf("recv checksum from cs wrong size %d", len(buf))
+		return
+	}
+	var cid uint64
+	var ver uint32
+	UnPack(buf, &cid, &ver)
+	if cid != chunkId || ver != version {
+		err = fmt.Errorf("recv checksum from cs bad cid %d ver %d", cid, ver)
+		return
+	}
+	if len(buf) == 13 {
+		// status is never ok without checksum
+		var status uint8
+		UnPack(buf[12:], &status)
+		err = MFSError(status)
+		return
+	}
+	buf = buf[12:]
+	return
+}
+
+// crc32 of the whole chunk
+func (c *CSClient) ChunkChecksum(chunkId uint64, version uint32) (crc uint32, err error) {
+	buf, err := c.checksumCmd(ANTOCS_GET_CHUNK_CHECKSUM, CSTOAN_CHUNK_CHECKSUM,
+		chunkId, version, 16)
+	if err != nil {
+		return
+	}
+	UnPack(buf, &crc)
+	return
+}
+
+func (c *CSClient) ChecksumTab(chunkId uint64, version uint32) (tab *ChecksumTab, err error) {
+	buf, err := c.checksumCmd(ANTOCS_GET_CHUNK_CHECKSUM_TAB, CSTOAN_CHUNK_CHECKSUM_TAB,
+		chunkId, version, 12+4*MFSBLOCKSINCHUNK)
+	if err != nil {
+		return
+	}
+	tab = new(ChecksumTab)
+	UnPack(buf, tab)
+	glog.V(10).Infof("checksum tab chunk %d version %d", chunkId, version)
+	return
+}
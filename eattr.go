@@ -0,0 +1,243 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sort"
+	"strings"
+)
+
+// gmode of get commands of sclass, trashtime and eattr
+const (
+	GMODE_NORMAL    = 0
+	GMODE_RECURSIVE = 1
+)
+
+// smode of set commands of sclass, trashtime and eattr
+const (
+	SMODE_SET      = 0
+	SMODE_INCREASE = 1 // add bits for eattr
+	SMODE_DECREASE = 2 // remove bits for eattr
+	SMODE_EXCHANGE = 3
+	SMODE_TMASK    = 3
+	SMODE_RMASK    = 4 // recursive
+)
+
+// extra attributes, like mfsseteattr
+type EAttr uint8
+
+const (
+	EATTR_NOOWNER     EAttr = 1 << iota // everybody is the owner
+	EATTR_NOACACHE                      // no attribute cache
+	EATTR_NOECACHE                      // no entry cache
+	EATTR_NODATACACHE                   // no data cache
+	EATTR_SNAPSHOT                      // can not be removed by snapshot overwrite
+	EATTR_UNDELETABLE                   // can not be removed
+	EATTR_APPENDONLY                    // data can only be appended
+	EATTR_IMMUTABLE                     // can not be changed
+)
+
+var eattrNames = []string{"noowner", "noattrcache", "noentrycache",
+	"nodatacache", "snapshot", "undeletable", "appendonly", "immutable"}
+
+func (e EAttr) Has(f EAttr) bool {
+	return e&f == f
+}
+
+// comma separated names, - if no bits
+func (e EAttr) String() string {
+	names := make([]string, 0, len(eattrNames))
+	for i, n := range eattrNames {
+		if e&(1<<uint(i)) != 0 {
+			names = append(names, n)
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ",")
+}
+
+// parse comma separated names like "noowner,snapshot"
+func ParseEAttr(str string) (e EAttr, err error) {
+	for _, n := range strings.Split(str, ",") {
+		n = strings.TrimSpace(n)
+		if len(n) == 0 || n == "-" {
+			continue
+		}
+		found := false
+		for i, name := range eattrNames {
+			if name == n {
+				e |= 1 << uint(i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = fmt.Errorf("unknown eattr %s", n)
+			return
+		}
+	}
+	return
+}
+
+// number of dirs and files by eattr
+type EAttrStats struct {
+	Dirs  map[EAttr]uint32
+	Files map[EAttr]uint32
+}
+
+// the eattr of a single inode
+func (st *EAttrStats) EAttr() (e EAttr, ok bool) {
+	for _, m := range []map[EAttr]uint32{st.Dirs, st.Files} {
+		for e = range m {
+			return e, len(st.Dirs)+len(st.Files) == 1
+		}
+	}
+	return
+}
+
+// eattrs sorted by value
+func sortedEAttrs(m map[EAttr]uint32) []EAttr {
+	res := make([]EAttr, 0, len(m))
+	for e := range m {
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+func (st *EAttrStats) String() string {
+	var b strings.Builder
+	for _, e := range sortedEAttrs(st.Files) {
+		fmt.Fprintf(&b, "files with eattr %s: %d\n", e, st.Files[e])
+	}
+	for _, e := range sortedEAttrs(st.Dirs) {
+		fmt.Fprintf(&b, "directories with eattr %s: %d\n", e, st.Dirs[e])
+	}
+	return b.String()
+}
+
+// counts of set commands of sclass, trashtime and eattr
+type ChangeInfo struct {
+	Changed       uint32
+	NotChanged    uint32
+	NotPermitted  uint32
+	QuotaExceeded uint32 // only for sclass
+}
+
+func (c *MAClient) GetEAttr(inode uint32, gmode uint8) (st *EAttrStats, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_GETEATTR, 0, inode, gmode)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 6)
+	if err != nil {
+		return
+	}
+	var ndirs, nfiles uint8
+	UnPack(buf[4:], &ndirs, &nfiles)
+	if len(buf) != 6+5*(int(ndirs)+int(nfiles)) {
+		err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+		return
+	}
+	st = &EAttrStats{
+		Dirs:  make(map[EAttr]uint32),
+		Files: make(map[EAttr]uint32),
+	}
+	pos := 6
+	for i := 0; i < int(ndirs)+int(nfiles); i++ {
+		var e EAttr
+		var cnt uint32
+		UnPack(buf[pos:], &e, &cnt)
+		pos += 5
+		if i < int(ndirs) {
+			st.Dirs[e] += cnt
+		} else {
+			st.Files[e] += cnt
+		}
+	}
+	glog.V(8).Infof("get eattr inode %d dirs %d files %d", inode, ndirs, nfiles)
+	return
+}
+
+// smode is one of SMODE_SET, SMODE_INCREASE and SMODE_DECREASE,
+// with SMODE_RMASK for recursive
+func (c *MAClient) SetEAttr(inode uint32, eattr EAttr, smode uint8) (ci *ChangeInfo, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_SETEATTR, 0, inode, c.identity().Uid, eattr, smode)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 16)
+	if err != nil {
+		return
+	}
+	ci = new(ChangeInfo)
+	UnPack(buf[4:], &ci.Changed, &ci.NotChanged, &ci.NotPermitted)
+	glog.V(8).Infof("set eattr inode %d %s smode %d changed %d notchanged %d "+
+		"notpermitted %d", inode, eattr, smode, ci.Changed, ci.NotChanged, ci.NotPermitted)
+	return
+}
+
+// like mfsgeteattr
+func (c *Client) GetEAttr(path string) (e EAttr, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	st, err := c.mc.GetEAttr(info.Inode, GMODE_NORMAL)
+	if err != nil {
+		return
+	}
+	e, ok := st.EAttr()
+	if !ok {
+		err = fmt.Errorf("got %d eattrs of %s", len(st.Dirs)+len(st.Files), path)
+	}
+	return
+}
+
+// like mfsgeteattr -r
+func (c *Client) GetEAttrRecursive(path string) (st *EAttrStats, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.GetEAttr(info.Inode, GMODE_RECURSIVE)
+}
+
+// like mfsseteattr and mfsdeleattr, see MAClient.SetEAttr for smode
+func (c *Client) SetEAttr(path string, eattr EAttr, smode uint8) (ci *ChangeInfo, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.SetEAttr(info.Inode, eattr, smode)
+}
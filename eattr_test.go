@@ -0,0 +1,77 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestEAttr(t *testing.T) {
+	e, err := ParseEAttr("noowner, snapshot,immutable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e != EATTR_NOOWNER|EATTR_SNAPSHOT|EATTR_IMMUTABLE {
+		t.Error("unexpect", uint8(e))
+	}
+	if !e.Has(EATTR_SNAPSHOT) || e.Has(EATTR_NOACACHE) {
+		t.Error("unexpect has")
+	}
+	if e.String() != "noowner,snapshot,immutable" {
+		t.Error("unexpect", e)
+	}
+	if EAttr(0).String() != "-" {
+		t.Error("unexpect empty")
+	}
+	if e, err = ParseEAttr("-"); err != nil || e != 0 {
+		t.Error("unexpect", e, err)
+	}
+	if _, err = ParseEAttr("nocache"); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestEAttrStats(t *testing.T) {
+	st := &EAttrStats{
+		Dirs:  map[EAttr]uint32{},
+		Files: map[EAttr]uint32{EATTR_NODATACACHE: 1},
+	}
+	if e, ok := st.EAttr(); !ok || e != EATTR_NODATACACHE {
+		t.Error("unexpect", e, ok)
+	}
+	st.Dirs[0] = 2
+	if _, ok := st.EAttr(); ok {
+		t.Error("unexpect single")
+	}
+	if st.String() != "files with eattr nodatacache: 1\ndirectories with eattr -: 2\n" {
+		t.Error("unexpect", st.String())
+	}
+}
+
+func TestSetEAttr(t *testing.T) {
+	t.Skip()
+	session(t, func(c *MAClient) {
+		ci, err := c.SetEAttr(MFS_ROOT_ID, EATTR_NOECACHE, SMODE_INCREASE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ci.Changed != 1 {
+			t.Error("unexpect", ci)
+		}
+		st, err := c.GetEAttr(MFS_ROOT_ID, GMODE_NORMAL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e, _ := st.EAttr(); !e.Has(EATTR_NOECACHE) {
+			t.Error("unexpect", e)
+		}
+		_, err = c.SetEAttr(MFS_ROOT_ID, EATTR_NOECACHE, SMODE_DECREASE)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
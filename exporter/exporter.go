@@ -0,0 +1,309 @@
+package exporter
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+// metrics of mfsmaster in the prometheus text format
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	mfs "github.com/Hacky-DH/moosefs-client"
+	"github.com/golang/glog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_INTERVAL = 30 * time.Second
+
+type Exporter struct {
+	client   *mfs.Client
+	interval time.Duration
+	mu       sync.RWMutex
+	body     []byte // metrics of the last refresh
+}
+
+// metrics are collected by client and its tools connection,
+// both are reused by all refreshes
+func New(c *mfs.Client, interval time.Duration) *Exporter {
+	if interval <= 0 {
+		interval = DEFAULT_INTERVAL
+	}
+	return &Exporter{
+		client:   c,
+		interval: interval,
+	}
+}
+
+// builder of the text format, HELP and TYPE are written once for a name
+type metrics struct {
+	buf   bytes.Buffer
+	typed map[string]bool
+}
+
+func newMetrics() *metrics {
+	return &metrics{typed: make(map[string]bool)}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels are name and value pairs
+func (m *metrics) gauge(name, help string, value float64, labels ...string) {
+	if !m.typed[name] {
+		m.typed[name] = true
+		fmt.Fprintf(&m.buf, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	m.buf.WriteString(name)
+	if len(labels) > 0 {
+		m.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&m.buf, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		m.buf.WriteByte('}')
+	}
+	m.buf.WriteByte(' ')
+	m.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	m.buf.WriteByte('\n')
+}
+
+func (m *metrics) statfs(st *mfs.StatInfo) {
+	m.gauge("mfs_statfs_total_bytes", "Total space.", float64(st.TotalSpace))
+	m.gauge("mfs_statfs_avail_bytes", "Available space.", float64(st.AvailSpace))
+	m.gauge("mfs_statfs_trash_bytes", "Space used by trash.", float64(st.TrashSpace))
+	m.gauge("mfs_statfs_reserved_bytes", "Reserved space.", float64(st.ReservedSpace))
+	m.gauge("mfs_statfs_inodes", "Number of inodes.", float64(st.Inodes))
+}
+
+func (m *metrics) info(info *mfs.MasterInfo) {
+	m.gauge("mfs_master_info", "Version and state of mfsmaster.", 1,
+		"version", info.VersionStr, "state", info.StateStr())
+	m.gauge("mfs_master_memory_bytes", "Memory used by mfsmaster.", float64(info.MemUsage))
+	m.gauge("mfs_master_cpu_usage_ratio", "CPU used by mfsmaster.",
+		float64(info.SysCpu)/1e6, "mode", "sys")
+	m.gauge("mfs_master_cpu_usage_ratio", "CPU used by mfsmaster.",
+		float64(info.UserCpu)/1e6, "mode", "user")
+	m.gauge("mfs_master_total_bytes", "Total space.", float64(info.TotalSpace))
+	m.gauge("mfs_master_avail_bytes", "Available space.", float64(info.AvailSpace))
+	m.gauge("mfs_master_trash_bytes", "Space used by trash.", float64(info.TrashSpace))
+	m.gauge("mfs_master_trash_nodes", "Files in trash.", float64(info.TrashNodes))
+	m.gauge("mfs_master_sustained_bytes", "Space used by sustained files.",
+		float64(info.SustainedSpace))
+	m.gauge("mfs_master_sustained_nodes", "Sustained files.", float64(info.SustainedNodes))
+	m.gauge("mfs_master_nodes", "Filesystem objects.", float64(info.AllNodes), "type", "all")
+	m.gauge("mfs_master_nodes", "Filesystem objects.", float64(info.DirNodes), "type", "dir")
+	m.gauge("mfs_master_nodes", "Filesystem objects.", float64(info.FileNodes), "type", "file")
+	m.gauge("mfs_master_chunks", "Chunks.", float64(info.Chunks))
+	m.gauge("mfs_master_chunk_copies", "Copies of all chunks.", float64(info.ChunkCopies))
+	m.gauge("mfs_master_chunk_copies_marked_for_removal",
+		"Copies on disks marked for removal.", float64(info.TdCopies))
+	m.gauge("mfs_master_last_store_timestamp_seconds", "Time of the last metadata save.",
+		float64(info.LastStoreTs))
+	m.gauge("mfs_master_last_store_duration_seconds", "Duration of the last metadata save.",
+		float64(info.LastStoreDuration)/1000)
+	m.gauge("mfs_master_last_store_status", "Status of the last metadata save.",
+		float64(info.LastStoreStatus))
+	m.gauge("mfs_master_metadata_version", "Metadata version.", float64(info.MetaVersion))
+}
+
+// each family is written in one block for all paths
+func (m *metrics) quotas(quotas mfs.QuotaInfoMap) {
+	type limit struct {
+		kind             string
+		curr, soft, hard float64
+	}
+	paths := make([]string, 0, len(quotas))
+	for p := range quotas {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	ratios := make([]float64, len(paths))
+	exceeded := make([]float64, len(paths))
+	limits := make([][]limit, len(paths))
+	for i, p := range paths {
+		info := quotas[p]
+		_, _, ratios[i] = info.Usage()
+		q := info.Quota()
+		if q.Exceeded {
+			exceeded[i] = 1
+		}
+		limits[i] = []limit{
+			{"inodes", float64(q.CurrInodes), float64(q.SoftInodes), float64(q.HardInodes)},
+			{"length", float64(q.CurrLength), float64(q.SoftLength), float64(q.HardLength)},
+			{"size", float64(q.CurrSize), float64(q.SoftSize), float64(q.HardSize)},
+			{"realsize", float64(q.CurrRealSize), float64(q.SoftRealSize),
+				float64(q.HardRealSize)},
+		}
+	}
+	for i, p := range paths {
+		m.gauge("mfs_quota_usage_ratio", "Max usage of soft limits.", ratios[i], "path", p)
+	}
+	for i, p := range paths {
+		m.gauge("mfs_quota_exceeded", "Quota is exceeded.", exceeded[i], "path", p)
+	}
+	for _, f := range []struct {
+		name, help string
+		value      func(l limit) float64
+	}{
+		{"mfs_quota_current", "Current usage.", func(l limit) float64 { return l.curr }},
+		{"mfs_quota_soft_limit", "Soft limit, 0 if not set.",
+			func(l limit) float64 { return l.soft }},
+		{"mfs_quota_hard_limit", "Hard limit, 0 if not set.",
+			func(l limit) float64 { return l.hard }},
+	} {
+		for i, p := range paths {
+			for _, l := range limits[i] {
+				m.gauge(f.name, f.help, f.value(l), "path", p, "kind", l.kind)
+			}
+		}
+	}
+}
+
+func (m *metrics) sessions(sessions []*mfs.SessionInfo) {
+	m.gauge("mfs_sessions", "Sessions of mfsmaster.", float64(len(sessions)))
+	for _, s := range sessions {
+		m.gauge("mfs_session_open_files", "Files opened by session.", float64(s.OpenFiles),
+			"id", strconv.FormatUint(uint64(s.Id), 10), "ip", s.IpStr(), "path", s.Path)
+	}
+}
+
+func (m *metrics) matrix(name string, cm *mfs.ChunksMatrix) {
+	for goal, row := range cm {
+		for copies, n := range row {
+			m.gauge("mfs_chunks", "Chunks by goal and valid copies, 10 means 10 or more.",
+				float64(n), "matrix", name, "goal", strconv.Itoa(goal),
+				"copies", strconv.Itoa(copies))
+		}
+	}
+}
+
+// collect all metrics once, a failed source is reported by
+// mfs_scrape_error and does not stop the others
+func (e *Exporter) Refresh() (err error) {
+	m := newMetrics()
+	// the sources on the connection without session fail together
+	tools, terr := e.client.Tools()
+	start := time.Now()
+	sources := []struct {
+		name    string
+		tools   bool
+		collect func() error
+	}{
+		{"statfs", false, func() error {
+			st, err := e.client.Statfs()
+			if err == nil {
+				m.statfs(st)
+			}
+			return err
+		}},
+		{"info", true, func() error {
+			info, err := tools.Info()
+			if err == nil {
+				m.info(info)
+			}
+			return err
+		}},
+		{"quota", true, func() error {
+			quotas, err := tools.AllQuotaInfo()
+			if err == nil {
+				m.quotas(quotas)
+			}
+			return err
+		}},
+		{"sessions", true, func() error {
+			sessions, err := tools.Sessions()
+			if err == nil {
+				m.sessions(sessions)
+			}
+			return err
+		}},
+		{"chunks", true, func() error {
+			all, err := tools.ChunksMatrix(mfs.CHUNKS_MATRIX_ALL)
+			if err != nil {
+				return err
+			}
+			regular, err := tools.ChunksMatrix(mfs.CHUNKS_MATRIX_REGULAR)
+			if err != nil {
+				return err
+			}
+			m.matrix("all", all)
+			m.matrix("regular", regular)
+			return nil
+		}},
+	}
+	failed := make([]string, 0)
+	for _, s := range sources {
+		var serr error
+		if s.tools && terr != nil {
+			serr = terr
+		} else {
+			serr = s.collect()
+		}
+		if serr != nil {
+			glog.Errorf("collect %s error %v", s.name, serr)
+			failed = append(failed, s.name)
+			err = serr
+		}
+	}
+	for _, s := range sources {
+		v := 0.0
+		for _, f := range failed {
+			if f == s.name {
+				v = 1
+			}
+		}
+		m.gauge("mfs_scrape_error", "Source failed in the last refresh.", v,
+			"source", s.name)
+	}
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	m.gauge("mfs_up", "All sources are collected in the last refresh.", up)
+	m.gauge("mfs_scrape_duration_seconds", "Duration of the last refresh.",
+		time.Since(start).Seconds())
+	m.gauge("mfs_scrape_timestamp_seconds", "Time of the last refresh.",
+		float64(start.Unix()))
+	e.mu.Lock()
+	e.body = m.buf.Bytes()
+	e.mu.Unlock()
+	glog.V(5).Infof("refresh metrics %d bytes failed %v", m.buf.Len(), failed)
+	return
+}
+
+// refresh every interval until ctx is done
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		e.Refresh()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// serve the metrics of the last refresh
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	body := e.body
+	e.mu.RUnlock()
+	if body == nil {
+		http.Error(w, "metrics are not collected yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(body)
+}
@@ -0,0 +1,100 @@
+package exporter
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"context"
+	mfs "github.com/Hacky-DH/moosefs-client"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	m := newMetrics()
+	m.gauge("mfs_test", "Test.", 1.5, "path", `/a"b\`)
+	m.gauge("mfs_test", "Test.", 2, "path", "/c", "kind", "size")
+	m.gauge("mfs_up", "Up.", 1)
+	expect := "# HELP mfs_test Test.\n# TYPE mfs_test gauge\n" +
+		"mfs_test{path=\"/a\\\"b\\\\\"} 1.5\n" +
+		"mfs_test{path=\"/c\",kind=\"size\"} 2\n" +
+		"# HELP mfs_up Up.\n# TYPE mfs_up gauge\nmfs_up 1\n"
+	if m.buf.String() != expect {
+		t.Errorf("unexpect\n%s", m.buf.String())
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	m := newMetrics()
+	cm := new(mfs.ChunksMatrix)
+	cm[2][1] = 5
+	m.matrix("all", cm)
+	if !strings.Contains(m.buf.String(),
+		"mfs_chunks{matrix=\"all\",goal=\"2\",copies=\"1\"} 5\n") {
+		t.Error("unexpect", m.buf.String())
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	m := newMetrics()
+	m.quotas(mfs.QuotaInfoMap{"/a": &mfs.QuotaInfo{}, "/b": &mfs.QuotaInfo{}})
+	// every family in one block under a single HELP
+	last := ""
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(m.buf.String()), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name := line[:strings.IndexByte(line, '{')]
+		if name != last && seen[name] {
+			t.Fatalf("unexpect %s interleaved\n%s", name, m.buf.String())
+		}
+		last = name
+		seen[name] = true
+	}
+	if len(seen) != 5 || strings.Count(m.buf.String(), "# HELP") != 5 {
+		t.Errorf("unexpect\n%s", m.buf.String())
+	}
+	if strings.Count(m.buf.String(), "mfs_quota_current{") != 8 {
+		t.Errorf("unexpect\n%s", m.buf.String())
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	e := New(nil, 0)
+	if e.interval != DEFAULT_INTERVAL {
+		t.Error("unexpect interval", e.interval)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Error("unexpect", rec.Code)
+	}
+	e.body = []byte("mfs_up 1\n")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "mfs_up 1\n" ||
+		!strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Error("unexpect", rec.Code, rec.Body.String())
+	}
+}
+
+func TestExporter(t *testing.T) {
+	t.Skip()
+	c, err := mfs.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	e := New(c, time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	e.Run(ctx)
+	t.Log(string(e.body))
+}
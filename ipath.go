@@ -0,0 +1,112 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"strings"
+)
+
+// inodes of all directories containing inode
+func (c *MAClient) Parents(inode uint32) (parents []uint32, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_PARENTS, 0, inode)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 4)
+	if err != nil {
+		return
+	}
+	if len(buf)%4 != 0 {
+		err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+		return
+	}
+	parents = make([]uint32, (len(buf)-4)/4)
+	UnPack(buf[4:], parents)
+	glog.V(8).Infof("parents of inode %d: %v", inode, parents)
+	return
+}
+
+// paths of all hard links of inode
+func (c *MAClient) Paths(inode uint32) (paths []string, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_PATHS, 0, inode)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 4)
+	if err != nil {
+		return
+	}
+	paths = make([]string, 0)
+	var length uint32
+	for pos := 4; pos < len(buf); pos += int(length) {
+		if pos+4 > len(buf) {
+			err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+			return
+		}
+		UnPack(buf[pos:], &length)
+		pos += 4
+		if pos+int(length) > len(buf) {
+			err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+			return
+		}
+		paths = append(paths, string(buf[pos:pos+int(length)]))
+	}
+	glog.V(8).Infof("paths of inode %d: %v", inode, paths)
+	return
+}
+
+// paths relative to subdir, paths outside of subdir are dropped
+func stripSubdir(paths []string, subdir string) (res []string) {
+	subdir = strings.TrimSuffix(subdir, "/")
+	res = make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		if len(subdir) == 0 {
+			res = append(res, p)
+		} else if p == subdir {
+			res = append(res, "/")
+		} else if strings.HasPrefix(p, subdir+"/") {
+			res = append(res, p[len(subdir):])
+		}
+	}
+	return
+}
+
+// paths of inode usable by this client, like mfsfilepaths
+func (c *Client) InodePaths(inode uint32) (paths []string, err error) {
+	paths, err = c.mc.Paths(inode)
+	if err != nil {
+		return
+	}
+	return stripSubdir(paths, c.mc.Subdir), nil
+}
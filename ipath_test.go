@@ -0,0 +1,57 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStripSubdir(t *testing.T) {
+	paths := []string{"/a/b", "/ab/c", "/a", "a/d", "/x"}
+	for _, c := range []struct {
+		subdir string
+		expect []string
+	}{
+		{"/", []string{"/a/b", "/ab/c", "/a", "/a/d", "/x"}},
+		{"/a", []string{"/b", "/", "/d"}},
+		{"/a/", []string{"/b", "/", "/d"}},
+		{"/y", []string{}},
+	} {
+		res := stripSubdir(paths, c.subdir)
+		if !reflect.DeepEqual(res, c.expect) {
+			t.Error("unexpect", c.subdir, res)
+		}
+	}
+}
+
+func TestInodePaths(t *testing.T) {
+	t.Skip()
+	session(t, func(c *MAClient) {
+		n := "testfile"
+		c.Unlink(MFS_ROOT_ID, n)
+		fi, err := c.Create(MFS_ROOT_ID, n, 0744)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Unlink(MFS_ROOT_ID, n)
+		parents, err := c.Parents(fi.Inode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parents) != 1 || parents[0] != MFS_ROOT_ID {
+			t.Error("unexpect", parents)
+		}
+		paths, err := c.Paths(fi.Inode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) != 1 || paths[0] != "/"+n {
+			t.Error("unexpect", paths)
+		}
+	})
+}
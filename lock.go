@@ -0,0 +1,402 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"math"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// for flock cmd
+const (
+	FLOCK_UNLOCK = iota
+	FLOCK_TRY_SHARED
+	FLOCK_LOCK_SHARED
+	FLOCK_TRY_EXCLUSIVE
+	FLOCK_LOCK_EXCLUSIVE
+	FLOCK_INTERRUPT
+	FLOCK_RELEASE
+)
+
+// for posix lock cmd
+const (
+	POSIX_LOCK_CMD_GET = iota
+	POSIX_LOCK_CMD_SET
+	POSIX_LOCK_CMD_TRY
+	POSIX_LOCK_CMD_INT
+)
+
+// for posix lock type
+const (
+	POSIX_LOCK_UNLCK = iota
+	POSIX_LOCK_RDLCK
+	POSIX_LOCK_WRLCK
+)
+
+// for File.Flock how, the same as flock(2)
+const (
+	LOCK_SH = 1 << iota
+	LOCK_EX
+	LOCK_NB
+	LOCK_UN
+)
+
+const LOCK_RETRY_INTERVAL = 50 * time.Millisecond
+const LOCK_RETRY_MAX_INTERVAL = time.Second
+
+var ErrWouldBlock = errors.New("lock is held by another owner")
+
+var lockReqId uint32
+var lockOwnerId uint32
+
+// unique lock owner in this process
+func newLockOwner() uint64 {
+	return uint64(os.Getpid())<<32 | uint64(atomic.AddUint32(&lockOwnerId, 1))
+}
+
+// blocking cmds make mfsmaster delay the answer, which would stall the
+// connection, so only try cmds are sent and waiting is done here
+func (c *MAClient) Flock(inode uint32, owner uint64, cmd uint8) (err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if cmd == FLOCK_LOCK_SHARED || cmd == FLOCK_LOCK_EXCLUSIVE {
+		err = fmt.Errorf("blocking flock cmd %d is not supported", cmd)
+		return
+	}
+	reqid := atomic.AddUint32(&lockReqId, 1)
+	buf, err := c.doCmd(CLTOMA_FUSE_FLOCK, 0, inode, reqid, owner, cmd)
+	if err != nil {
+		return
+	}
+	err = c.checkBuf(buf, 0, 5)
+	if err != nil {
+		return
+	}
+	err = getStatus(buf[4:])
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("flock inode %d owner %x cmd %d", inode, owner, cmd)
+	return
+}
+
+// a byte range [Start, End) lock
+type PosixLock struct {
+	Pid   uint32
+	Type  uint8
+	Start uint64
+	End   uint64
+}
+
+// for POSIX_LOCK_CMD_GET, lk is filled with the conflicting lock
+func (c *MAClient) PosixLock(inode uint32, owner uint64, cmd uint8,
+	lk *PosixLock) (err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if cmd != POSIX_LOCK_CMD_GET && cmd != POSIX_LOCK_CMD_TRY &&
+		!(cmd == POSIX_LOCK_CMD_SET && lk.Type == POSIX_LOCK_UNLCK) {
+		err = fmt.Errorf("blocking posix lock cmd %d is not supported", cmd)
+		return
+	}
+	reqid := atomic.AddUint32(&lockReqId, 1)
+	buf, err := c.doCmd(CLTOMA_FUSE_POSIX_LOCK, 0, inode, reqid, owner,
+		lk.Pid, cmd, lk.Type, lk.Start, lk.End)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		if err != nil {
+			return
+		}
+	} else if cmd == POSIX_LOCK_CMD_GET {
+		err = c.checkBuf(buf, 0, 25)
+		if err != nil {
+			return
+		}
+		UnPack(buf[4:], &lk.Pid, &lk.Type, &lk.Start, &lk.End)
+	} else {
+		err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+		return
+	}
+	glog.V(8).Infof("posix lock inode %d owner %x cmd %d type %d [%d,%d)",
+		inode, owner, cmd, lk.Type, lk.Start, lk.End)
+	return
+}
+
+// call try until it does not return ErrWouldBlock or ctx is done
+func waitLock(ctx context.Context, try func() error) (err error) {
+	interval := LOCK_RETRY_INTERVAL
+	for {
+		err = try()
+		if err != ErrWouldBlock {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(interval):
+		}
+		interval *= 2
+		if interval > LOCK_RETRY_MAX_INTERVAL {
+			interval = LOCK_RETRY_MAX_INTERVAL
+		}
+	}
+}
+
+func lockError(err error) error {
+	if err == MFS_ERROR_EAGAIN {
+		return ErrWouldBlock
+	}
+	return err
+}
+
+// how is LOCK_SH, LOCK_EX or LOCK_UN, optionally with LOCK_NB.
+// without LOCK_NB it waits until the lock is acquired or ctx is done
+func (f *File) Flock(ctx context.Context, how int) (err error) {
+	var cmd uint8
+	switch {
+	case how&LOCK_UN != 0:
+		cmd = FLOCK_UNLOCK
+	case how&LOCK_EX != 0:
+		cmd = FLOCK_TRY_EXCLUSIVE
+	case how&LOCK_SH != 0:
+		cmd = FLOCK_TRY_SHARED
+	default:
+		err = fmt.Errorf("invalid flock operation %d", how)
+		return
+	}
+	try := func() error {
+		return lockError(f.client.mc.Flock(f.info.Inode, f.owner, cmd))
+	}
+	if cmd == FLOCK_UNLOCK || how&LOCK_NB != 0 {
+		err = try()
+	} else {
+		err = waitLock(ctx, try)
+	}
+	if err != nil {
+		return
+	}
+	f.client.owner().lockMu.Lock()
+	f.flocked = cmd != FLOCK_UNLOCK
+	f.client.owner().lockMu.Unlock()
+	f.client.trackLocks(f)
+	return
+}
+
+// length 0 means to the end of file
+func lockRange(start, length uint64) (end uint64, err error) {
+	if length == 0 {
+		end = math.MaxUint64
+		return
+	}
+	end = start + length
+	if end < start {
+		err = fmt.Errorf("lock range overflow")
+	}
+	return
+}
+
+func (f *File) posixLock(owner uint64, cmd, ltype uint8,
+	start, length uint64) (lk *PosixLock, err error) {
+	end, err := lockRange(start, length)
+	if err != nil {
+		return
+	}
+	lk = &PosixLock{
+		Pid:   uint32(os.Getpid()),
+		Type:  ltype,
+		Start: start,
+		End:   end,
+	}
+	err = lockError(f.client.mc.PosixLock(f.info.Inode, owner, cmd, lk))
+	return
+}
+
+// [start, end) ranges held by a lock owner, sorted and not overlapping
+type lockRanges [][2]uint64
+
+func (r lockRanges) add(start, end uint64) lockRanges {
+	r = r.remove(start, end)
+	i := 0
+	for i < len(r) && r[i][0] < start {
+		i++
+	}
+	r = append(r, [2]uint64{})
+	copy(r[i+1:], r[i:])
+	r[i] = [2]uint64{start, end}
+	// merge adjacent ranges
+	merged := r[:1]
+	for _, x := range r[1:] {
+		last := &merged[len(merged)-1]
+		if x[0] <= last[1] {
+			if x[1] > last[1] {
+				last[1] = x[1]
+			}
+		} else {
+			merged = append(merged, x)
+		}
+	}
+	return merged
+}
+
+func (r lockRanges) remove(start, end uint64) lockRanges {
+	var left lockRanges
+	for _, x := range r {
+		if x[1] <= start || x[0] >= end {
+			left = append(left, x)
+			continue
+		}
+		if x[0] < start {
+			left = append(left, [2]uint64{x[0], start})
+		}
+		if x[1] > end {
+			left = append(left, [2]uint64{end, x[1]})
+		}
+	}
+	return left
+}
+
+// ltype is POSIX_LOCK_RDLCK or POSIX_LOCK_WRLCK,
+// wait until the lock is acquired or ctx is done
+func (f *File) Lock(ctx context.Context, owner uint64, ltype uint8,
+	start, length uint64) (err error) {
+	if ltype != POSIX_LOCK_RDLCK && ltype != POSIX_LOCK_WRLCK {
+		err = fmt.Errorf("invalid lock type %d", ltype)
+		return
+	}
+	err = waitLock(ctx, func() error {
+		_, e := f.posixLock(owner, POSIX_LOCK_CMD_TRY, ltype, start, length)
+		return e
+	})
+	if err != nil {
+		return
+	}
+	f.addLockRange(owner, start, length)
+	return
+}
+
+// return ErrWouldBlock if the range is locked by another owner
+func (f *File) TryLock(owner uint64, ltype uint8, start, length uint64) (err error) {
+	if ltype != POSIX_LOCK_RDLCK && ltype != POSIX_LOCK_WRLCK {
+		err = fmt.Errorf("invalid lock type %d", ltype)
+		return
+	}
+	_, err = f.posixLock(owner, POSIX_LOCK_CMD_TRY, ltype, start, length)
+	if err != nil {
+		return
+	}
+	f.addLockRange(owner, start, length)
+	return
+}
+
+// the owner is forgotten when it holds no range
+func (f *File) Unlock(owner uint64, start, length uint64) (err error) {
+	_, err = f.posixLock(owner, POSIX_LOCK_CMD_SET, POSIX_LOCK_UNLCK,
+		start, length)
+	if err != nil {
+		return
+	}
+	end, _ := lockRange(start, length)
+	c := f.client.owner()
+	c.lockMu.Lock()
+	if r, ok := f.owners[owner]; ok {
+		if r = r.remove(start, end); len(r) > 0 {
+			f.owners[owner] = r
+		} else {
+			delete(f.owners, owner)
+		}
+	}
+	c.lockMu.Unlock()
+	f.client.trackLocks(f)
+	return
+}
+
+// get the first lock which conflicts with the given one, nil if none
+func (f *File) GetLock(owner uint64, ltype uint8, start,
+	length uint64) (lk *PosixLock, err error) {
+	lk, err = f.posixLock(owner, POSIX_LOCK_CMD_GET, ltype, start, length)
+	if err != nil {
+		return
+	}
+	if lk.Type == POSIX_LOCK_UNLCK {
+		lk = nil
+	}
+	return
+}
+
+func (f *File) addLockRange(owner uint64, start, length uint64) {
+	end, _ := lockRange(start, length)
+	c := f.client.owner()
+	c.lockMu.Lock()
+	f.owners[owner] = f.owners[owner].add(start, end)
+	c.lockMu.Unlock()
+	f.client.trackLocks(f)
+}
+
+// release all flock and posix locks held by this file
+func (f *File) releaseLocks() (err error) {
+	c := f.client
+	c.owner().lockMu.Lock()
+	owners := f.owners
+	f.owners = make(map[uint64]lockRanges)
+	flocked := f.flocked
+	f.flocked = false
+	delete(c.owner().locked, f)
+	c.owner().lockMu.Unlock()
+	if c.owner().mc == nil {
+		return
+	}
+	if flocked {
+		err = c.mc.Flock(f.info.Inode, f.owner, FLOCK_RELEASE)
+	}
+	for owner := range owners {
+		if e := f.Unlock(owner, 0, 0); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// remember files holding locks, they are released when client is closed
+func (c *Client) trackLocks(f *File) {
+	c = c.owner()
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+	if f.flocked || len(f.owners) > 0 {
+		c.locked[f] = true
+	} else {
+		delete(c.locked, f)
+	}
+}
+
+func (c *Client) releaseLocks() {
+	c.lockMu.Lock()
+	files := make([]*File, 0, len(c.locked))
+	for f := range c.locked {
+		files = append(files, f)
+	}
+	c.lockMu.Unlock()
+	for _, f := range files {
+		if err := f.releaseLocks(); err != nil {
+			glog.V(5).Infof("release locks of %s error %v", f.Path, err)
+		}
+	}
+}
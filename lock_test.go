@@ -0,0 +1,128 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLockRange(t *testing.T) {
+	end, err := lockRange(10, 0)
+	if err != nil || end != math.MaxUint64 {
+		t.Error("unexpect", end, err)
+	}
+	end, err = lockRange(10, 5)
+	if err != nil || end != 15 {
+		t.Error("unexpect", end, err)
+	}
+	_, err = lockRange(math.MaxUint64-1, 5)
+	if err == nil {
+		t.Error("unexpect")
+	}
+}
+
+func TestLockRanges(t *testing.T) {
+	var r lockRanges
+	r = r.add(10, 20)
+	r = r.add(0, 5)
+	r = r.add(15, 30)
+	if !reflect.DeepEqual(r, lockRanges{{0, 5}, {10, 30}}) {
+		t.Error("unexpect", r)
+	}
+	r = r.remove(12, 14)
+	if !reflect.DeepEqual(r, lockRanges{{0, 5}, {10, 12}, {14, 30}}) {
+		t.Error("unexpect", r)
+	}
+	r = r.add(5, 10)
+	if !reflect.DeepEqual(r, lockRanges{{0, 12}, {14, 30}}) {
+		t.Error("unexpect", r)
+	}
+	if r = r.remove(0, math.MaxUint64); len(r) != 0 {
+		t.Error("unexpect", r)
+	}
+}
+
+func TestWaitLock(t *testing.T) {
+	n := 0
+	err := waitLock(context.Background(), func() error {
+		n++
+		if n < 3 {
+			return ErrWouldBlock
+		}
+		return nil
+	})
+	if err != nil || n != 3 {
+		t.Error("unexpect", n, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+	err = waitLock(ctx, func() error {
+		return ErrWouldBlock
+	})
+	if err != context.DeadlineExceeded {
+		t.Error("unexpect", err)
+	}
+	if lockError(MFS_ERROR_EAGAIN) != ErrWouldBlock {
+		t.Error("unexpect")
+	}
+}
+
+func TestFlock(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	n := "testlockfile"
+	f1, err := c.OpenOrCreate(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Unlink(n)
+	f2, err := c.Open(n, WANT_READ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	err = f1.Flock(ctx, LOCK_EX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f2.Flock(ctx, LOCK_SH|LOCK_NB)
+	if err != ErrWouldBlock {
+		t.Fatal("unexpect ", err)
+	}
+	err = f1.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f2.Flock(ctx, LOCK_SH|LOCK_NB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f2.TryLock(1, POSIX_LOCK_WRLCK, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lk, err := f2.GetLock(2, POSIX_LOCK_WRLCK, 50, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lk == nil || lk.Type != POSIX_LOCK_WRLCK {
+		t.Fatal("unexpect ", lk)
+	}
+	err = f2.Unlock(1, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
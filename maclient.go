@@ -8,7 +8,6 @@ Copyright (c) 2019 DHacky
 
 import (
 	"bytes"
-	"crypto/md5"
 	"fmt"
 	"github.com/golang/glog"
 	"io"
@@ -21,26 +20,35 @@ import (
 
 // mfs master client
 type MAClient struct {
+	*masterConn
+	cred *Credentials // identity of requests
+}
+
+// connection and session shared by all views of a MAClient
+type masterConn struct {
 	conn      net.Conn
 	addr      string
 	Password  string
+	MD5Pass   string // hex md5 of password like mfsmd5pass
 	Subdir    string //remote subdir
 	RootPath  string //local root path
-	uid       uint32
-	gid       uint32
 	sessionId uint32
+	Params    *SessionParams // from the registration of session
+	cmdLock   sync.Mutex     // one command and its answer at a time
 	sync.Mutex
 	Version
 }
 
 func NewMAClientPwd(addr, pwd string, heartbeat bool) (c *MAClient) {
 	c = &MAClient{
-		Password: pwd,
-		uid:      uint32(os.Getuid()),
-		gid:      uint32(os.Getgid()),
-		Subdir:   "/",
-		RootPath: "/mnt/client",
-	}
+		masterConn: &masterConn{
+			Password: pwd,
+			Subdir:   "/",
+			RootPath: "/mnt/client",
+		},
+		cred: ProcessCredentials(),
+	}
+	c.addr = addr
 	ip := strings.Split(addr, ":")
 	if len(ip) < 2 {
 		//mfs client port
@@ -145,6 +153,13 @@ func (c *MAClient) Recv(buf []byte) (n int, err error) {
 }
 
 func (c *MAClient) doCmd(cmd uint32, args ...interface{}) (r []byte, err error) {
+	if writeCmds[cmd] {
+		if err = c.writable(); err != nil {
+			return
+		}
+	}
+	c.cmdLock.Lock()
+	defer c.cmdLock.Unlock()
 	msg := PackCmd(cmd, args...)
 	if err = c.Send(msg); err != nil {
 		err = fmt.Errorf("send error %s", err.Error())
@@ -191,7 +206,7 @@ func getStatus(buf []byte) (err error) {
 	var code uint8
 	UnPack(buf, &code)
 	if code != 0 {
-		err = fmt.Errorf("got error from mfsmaster: %s", MFSStrerror(code))
+		err = MFSError(code)
 		return
 	}
 	return
@@ -220,22 +235,13 @@ func (c *MAClient) CreateSession() (err error) {
 	}
 	var buf []byte
 	if c.sessionId == 0 {
-		pwFinal := make([]byte, 16)
-		if len(c.Password) > 0 {
-			buf, err = c.doCmd(CLTOMA_FUSE_REGISTER, FUSE_REGISTER_BLOB_ACL,
-				REGISTER_GETRANDOM)
-			if err == nil && len(buf) == 32 {
-				pwMd5 := md5.Sum([]byte(c.Password))
-				md := md5.New()
-				md.Write(buf[:16])
-				md.Write(pwMd5[:])
-				md.Write(buf[16:])
-				pwFinal = md.Sum(nil)
-			}
+		var code []byte
+		if code, err = c.passcode(); err != nil {
+			return
 		}
 		buf, err = c.doCmd(CLTOMA_FUSE_REGISTER, FUSE_REGISTER_BLOB_ACL,
 			REGISTER_NEWSESSION, c.Version, len(c.RootPath), c.RootPath,
-			len(c.Subdir)+1, c.Subdir+"\000", pwFinal)
+			len(c.Subdir)+1, c.Subdir+"\000", code)
 	} else {
 		buf, err = c.doCmd(CLTOMA_FUSE_REGISTER, FUSE_REGISTER_BLOB_ACL,
 			REGISTER_RECONNECT, c.sessionId, c.Version)
@@ -244,7 +250,7 @@ func (c *MAClient) CreateSession() (err error) {
 		return
 	}
 	if len(buf) == 1 {
-		err = getStatus(buf)
+		err = authStatus(buf)
 		if err != nil {
 			return
 		}
@@ -257,22 +263,34 @@ func (c *MAClient) CreateSession() (err error) {
 		err = fmt.Errorf("got wrong size %d<43 from mfsmaster", len(buf))
 		return
 	}
-	var id uint32
-	UnPack(buf[4:], &id)
+	params, id := parseSessionParams(buf)
 	if 0 != c.sessionId {
 		c.CloseSession()
 	}
 	c.sessionId = id
-	glog.V(8).Infof("create new session id %d", id)
+	c.setParams(params)
+	glog.V(8).Infof("create new session id %d flags 0x%x", id, params.Flags)
 	return
 }
 
 func (c *MAClient) CloseSession() (err error) {
+	var metaId uint64
+	if c.Params != nil {
+		metaId = c.Params.MetaId
+	}
+	return c.closeSession(metaId)
+}
+
+// metaid is sent if not 0, mfsmaster checks it against the session
+func (c *MAClient) closeSession(metaId uint64) (err error) {
 	if c.sessionId == 0 {
 		return
 	}
-	buf, err := c.doCmd(CLTOMA_FUSE_REGISTER, FUSE_REGISTER_BLOB_ACL,
-		REGISTER_CLOSESESSION, c.sessionId)
+	args := []interface{}{FUSE_REGISTER_BLOB_ACL, REGISTER_CLOSESESSION, c.sessionId}
+	if metaId != 0 {
+		args = append(args, metaId)
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_REGISTER, args...)
 	if err != nil {
 		return
 	}
@@ -299,35 +317,13 @@ func (c *MAClient) RemoveSession(sessionId uint32) (err error) {
 }
 
 func (c *MAClient) ListSession() (ids []uint32, err error) {
-	buf, err := c.doCmd(CLTOMA_SESSION_LIST, uint8(2))
+	sessions, err := c.Sessions()
 	if err != nil {
 		return
 	}
-	if len(buf) <= 2 {
-		return
-	}
-	var stats uint16
-	UnPack(buf, &stats)
-	if stats != 16 {
-		err = fmt.Errorf("list session got wrong stats %d!=16 from mfsmaster", stats)
-		return
-	}
-	if len(buf) < 188 {
-		err = fmt.Errorf("list session got small size %d<188 from mfsmaster", len(buf))
-		return
-	}
-	ids = make([]uint32, 0)
-	var id uint32
-	pos := 2
-	for pos < len(buf) {
-		UnPack(buf[pos:], &id)
-		ids = append(ids, id)
-		glog.V(8).Infof("list session id %d", id)
-		pos += 21
-		UnPack(buf[pos:], &id) // ileng
-		pos += 4 + int(id)
-		UnPack(buf[pos:], &id) // pleng
-		pos += 4 + int(id) + 27 + 128
+	ids = make([]uint32, 0, len(sessions))
+	for _, s := range sessions {
+		ids = append(ids, s.Id)
 	}
 	return
 }
@@ -424,7 +420,7 @@ func (c *MAClient) Access(inode uint32, mode uint16) (err error) {
 	if err = checkInodeName(&inode, nil); err != nil {
 		return
 	}
-	buf, err := c.doCmd(CLTOMA_FUSE_ACCESS, 0, inode, c.uid, 1, c.gid, mode)
+	buf, err := c.doCmd(CLTOMA_FUSE_ACCESS, 0, inode, c.ids(), mode)
 	if err != nil {
 		return
 	}
@@ -444,7 +440,7 @@ func (c *MAClient) Lookup(parent uint32, name string) (fi *FileInfo, err error)
 		return
 	}
 	buf, err := c.doCmd(CLTOMA_FUSE_LOOKUP, 0, parent, uint8(len(name)),
-		name, c.uid, 1, c.gid)
+		name, c.ids())
 	if err != nil {
 		return
 	}
@@ -476,7 +472,7 @@ func (c *MAClient) Mkdir(parent uint32, name string,
 		return
 	}
 	buf, err := c.doCmd(CLTOMA_FUSE_MKDIR, 0, parent, uint8(len(name)),
-		name, mode, uint16(0), c.uid, 1, c.gid, uint8(0))
+		name, mode, uint16(0), c.ids(), uint8(0))
 	if err != nil {
 		return
 	}
@@ -508,7 +504,7 @@ func (c *MAClient) Mknod(parent uint32, name string,
 		return
 	}
 	buf, err := c.doCmd(CLTOMA_FUSE_MKNOD, 0, parent, uint8(len(name)),
-		name, uint8(1), mode, uint16(0), c.uid, 1, c.gid, 0)
+		name, uint8(1), mode, uint16(0), c.ids(), 0)
 	if err != nil {
 		return
 	}
@@ -539,7 +535,7 @@ func (c *MAClient) remove(parent uint32, name string, cmd uint32) (err error) {
 		return
 	}
 	buf, err := c.doCmd(cmd, 0, parent, uint8(len(name)),
-		name, c.uid, 1, c.gid)
+		name, c.ids())
 	if err != nil {
 		return
 	}
@@ -571,44 +567,14 @@ type ReaddirInfo struct {
 
 type ReaddirInfoMap map[uint32]*ReaddirInfo
 
+// entries keyed by inode, hard links of the same inode collapse,
+// see ReaddirEach for all names in order
 func (c *MAClient) Readdir(parent uint32) (infoMap ReaddirInfoMap, err error) {
-	if err = checkInodeName(&parent, nil); err != nil {
-		return
-	}
-	//max entries 0xffff
-	buf, err := c.doCmd(CLTOMA_FUSE_READDIR, 0, parent, c.uid, 1, c.gid,
-		uint8(0), 0xffff, uint64(0))
-	if err != nil {
-		return
-	}
-	if len(buf) == 5 {
-		err = c.checkBuf(buf, 0, 5)
-		if err != nil {
-			return
-		}
-		err = getStatus(buf[4:])
-		return
-	}
-	// include . and  ..
-	err = c.checkBuf(buf, 0, 27)
-	if err != nil {
-		return
-	}
-	pos := 12
-	var sz uint8
 	infoMap = make(ReaddirInfoMap)
-	for pos < len(buf) {
-		info := new(ReaddirInfo)
-		UnPack(buf[pos:], &sz)
-		pos++
-		info.Name = string(buf[pos : pos+int(sz)])
-		pos += int(sz)
-		UnPack(buf[pos:], &info.Inode, &info.Type)
-		pos += 5
-		infoMap[info.Inode] = info
-		glog.V(10).Infof("readdir inode %d name %s", info.Inode, info.Name)
-	}
-	glog.V(8).Infof("readdir parent %d len %d", parent, len(infoMap))
+	err = c.ReaddirEach(parent, false, func(e *DirEntry) error {
+		infoMap[e.Inode] = &ReaddirInfo{Type: e.Type, Inode: e.Inode, Name: e.Name}
+		return nil
+	})
 	return
 }
 
@@ -620,53 +586,13 @@ type ReaddirInfoAttr struct {
 
 type ReaddirInfoAttrMap map[uint32]*ReaddirInfoAttr
 
+// like Readdir with attributes
 func (c *MAClient) ReaddirAttr(parent uint32) (infoMap ReaddirInfoAttrMap, err error) {
-	if err = checkInodeName(&parent, nil); err != nil {
-		return
-	}
-	//max entries 0xffff
-	buf, err := c.doCmd(CLTOMA_FUSE_READDIR, 0, parent, c.uid, 1, c.gid,
-		uint8(1), 0xffff, uint64(0))
-	if err != nil {
-		return
-	}
-	if len(buf) == 5 {
-		err = c.checkBuf(buf, 0, 5)
-		if err != nil {
-			return
-		}
-		err = getStatus(buf[4:])
-		return
-	}
-	// include . and  ..
-	err = c.checkBuf(buf, 0, 79)
-	if err != nil {
-		return
-	}
-	pos := 12
-	var sz uint8
-	var n uint32
-	var fi *FileInfo
 	infoMap = make(ReaddirInfoAttrMap)
-	for pos < len(buf) {
-		info := new(ReaddirInfoAttr)
-		UnPack(buf[pos:], &sz)
-		pos++
-		info.Name = string(buf[pos : pos+int(sz)])
-		pos += int(sz)
-		UnPack(buf[pos:], &info.Inode)
-		pos += 4
-		n, fi, err = parseFileInfo(info.Inode, buf[pos:])
-		if err != nil {
-			return
-		}
-		info.Info = fi
-		pos += int(n)
-		infoMap[info.Inode] = info
-		glog.V(10).Infof("readdir attr inode %d name %s mode %s",
-			info.Inode, info.Name, info.Info.Mode)
-	}
-	glog.V(8).Infof("readdir attr parent %d len %d", parent, len(infoMap))
+	err = c.ReaddirEach(parent, true, func(e *DirEntry) error {
+		infoMap[e.Inode] = &ReaddirInfoAttr{Inode: e.Inode, Name: e.Name, Info: e.Info}
+		return nil
+	})
 	return
 }
 
@@ -822,7 +748,7 @@ func (c *MAClient) Open(inode uint32, flags uint8) (fi *FileInfo, err error) {
 	if err = checkInodeName(&inode, nil); err != nil {
 		return
 	}
-	buf, err := c.doCmd(CLTOMA_FUSE_OPEN, 0, inode, c.uid, 1, c.gid, flags)
+	buf, err := c.doCmd(CLTOMA_FUSE_OPEN, 0, inode, c.ids(), flags)
 	if err != nil {
 		return
 	}
@@ -853,7 +779,7 @@ func (c *MAClient) Create(parent uint32, name string,
 		return
 	}
 	buf, err := c.doCmd(CLTOMA_FUSE_CREATE, 0, parent, uint8(len(name)),
-		name, mode, uint16(0), c.uid, 1, c.gid)
+		name, mode, uint16(0), c.ids())
 	if err != nil {
 		return
 	}
@@ -925,7 +851,7 @@ func (c *MAClient) SetAttr(inode uint32, setmask uint8, mode uint16,
 	if err = checkInodeName(&inode, nil); err != nil {
 		return
 	}
-	buf, err := c.doCmd(CLTOMA_FUSE_SETATTR, 0, inode, uint8(0), c.uid, 1, c.gid,
+	buf, err := c.doCmd(CLTOMA_FUSE_SETATTR, 0, inode, uint8(0), c.ids(),
 		setmask, mode, uid, gid, atime, mtime, uint8(0))
 	if err != nil {
 		return
@@ -1134,7 +1060,7 @@ func (c *MAClient) Symlink(parent uint32, name string, path string,
 		return
 	}
 	buf, err := c.doCmd(CLTOMA_FUSE_SYMLINK, 0, parent, uint8(len(name)),
-		name, uint32(len(path)), path, c.uid, 1, c.gid)
+		name, uint32(len(path)), path, c.ids())
 	if err != nil {
 		return
 	}
@@ -1170,7 +1096,7 @@ func (c *MAClient) Link(inode, inodeDst uint32, nameDst string,
 		return
 	}
 	buf, err := c.doCmd(CLTOMA_FUSE_LINK, 0, inode, inodeDst,
-		uint8(len(nameDst)), nameDst, c.uid, 1, c.gid)
+		uint8(len(nameDst)), nameDst, c.ids())
 	if err != nil {
 		return
 	}
@@ -1236,7 +1162,7 @@ func (c *MAClient) Rename(inodeSrc uint32, nameSrc string, inodeDst uint32,
 		return
 	}
 	buf, err := c.doCmd(CLTOMA_FUSE_RENAME, 0, inodeSrc, uint8(len(nameSrc)),
-		nameSrc, inodeDst, uint8(len(nameDst)), nameDst, c.uid, 1, c.gid)
+		nameSrc, inodeDst, uint8(len(nameDst)), nameDst, c.ids())
 	if err != nil {
 		return
 	}
@@ -1262,12 +1188,21 @@ func (c *MAClient) Rename(inodeSrc uint32, nameSrc string, inodeDst uint32,
 	return
 }
 
+// for truncate flags
+const (
+	TRUNCATE_FLAG_OPENED = 1 << iota
+	TRUNCATE_FLAG_UPDATE
+	TRUNCATE_FLAG_TIMEFIX
+)
+
 // msgid:32 inode:32 flags:8 uid:32 gcnt:32 gcnt * [ gid:32 ] length:64 (version >= 2.0.89/3.0.25)
-func (c *MAClient) Truncate(inode uint32, flags uint8) (fi *FileInfo, err error) {
+func (c *MAClient) Truncate(inode uint32, flags uint8,
+	length uint64) (fi *FileInfo, err error) {
 	if err = checkInodeName(&inode, nil); err != nil {
 		return
 	}
-	buf, err := c.doCmd(CLTOMA_FUSE_TRUNCATE, 0, inode, flags, c.uid, 1, c.gid)
+	buf, err := c.doCmd(CLTOMA_FUSE_TRUNCATE, 0, inode, flags, c.ids(),
+		length)
 	if err != nil {
 		return
 	}
@@ -1287,6 +1222,6 @@ func (c *MAClient) Truncate(inode uint32, flags uint8) (fi *FileInfo, err error)
 	if err != nil {
 		return
 	}
-	glog.V(8).Infof("truncate %d", inode)
+	glog.V(8).Infof("truncate %d length %d", inode, length)
 	return
 }
@@ -15,7 +15,6 @@ import (
 func init() {
 	flag.Set("logtostderr", "true")
 	flag.Set("v", "10")
-	flag.Parse()
 }
 
 func maclient() *MAClient {
@@ -0,0 +1,232 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+)
+
+// trash_id of CLTOMA_FUSE_GETTRASH for the whole trash
+const TRASH_ID_ALL = 0xFFFFFFFF
+
+// goal and trashtime limits of a session from mfsexports.cfg
+type SessionLimits struct {
+	MinGoal      uint8
+	MaxGoal      uint8
+	MinTrashTime uint32
+	MaxTrashTime uint32
+}
+
+// a session like mfsmount -m, only for trash and sustained files
+type MetaClient struct {
+	mc     *MAClient
+	MetaId uint64
+	Flags  uint8 // SESFLAG_*
+	SessionLimits
+}
+
+func NewMetaClientFull(addr, password string) (*MetaClient, error) {
+	return newMetaClient(NewMAClientPwd(addr, password, true))
+}
+
+func newMetaClient(mc *MAClient) (m *MetaClient, err error) {
+	m = &MetaClient{mc: mc}
+	err = m.mc.MasterVersion()
+	if err != nil {
+		m.mc.Close()
+		return
+	}
+	code, err := m.mc.passcode()
+	if err != nil {
+		m.mc.Close()
+		return
+	}
+	buf, err := m.mc.doCmd(CLTOMA_FUSE_REGISTER, FUSE_REGISTER_BLOB_ACL,
+		REGISTER_NEWMETASESSION, m.mc.Version, len(m.mc.RootPath), m.mc.RootPath,
+		code)
+	if err == nil {
+		err = m.register(buf)
+	}
+	if err != nil {
+		m.mc.Close()
+		return
+	}
+	return
+}
+
+func NewMetaClient() (m *MetaClient, err error) {
+	cfg, err := LoadConfig(nil, "")
+	if err != nil {
+		return
+	}
+	return cfg.NewMetaClient()
+}
+
+func (m *MetaClient) register(buf []byte) (err error) {
+	if len(buf) == 1 {
+		return authStatus(buf)
+	}
+	if len(buf) != 27 {
+		err = fmt.Errorf("got wrong size %d!=27 from mfsmaster", len(buf))
+		return
+	}
+	var ver uint32
+	UnPack(buf, &ver, &m.mc.sessionId, &m.MetaId, &m.Flags, &m.MinGoal,
+		&m.MaxGoal, &m.MinTrashTime, &m.MaxTrashTime)
+	glog.V(8).Infof("create new meta session id %d", m.mc.sessionId)
+	return
+}
+
+func (m *MetaClient) Close() {
+	if m.mc != nil {
+		m.mc.closeSession(m.MetaId)
+		m.mc.Close()
+		m.mc = nil
+	}
+}
+
+func (m *MetaClient) SessionId() uint32 {
+	return m.mc.sessionId
+}
+
+// files in trash, names are like 0000000A|path/of/file
+func (c *MAClient) GetTrash(trashId uint32) (entries []*DetachedEntry, err error) {
+	var buf []byte
+	if c.Version.LessThan(3, 0, 64) {
+		buf, err = c.doCmd(CLTOMA_FUSE_GETTRASH, 0)
+	} else {
+		buf, err = c.doCmd(CLTOMA_FUSE_GETTRASH, 0, trashId)
+	}
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 4)
+	if err != nil {
+		return
+	}
+	entries, err = parseNameInodes(buf[4:])
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("trash %d files %d", trashId, len(entries))
+	return
+}
+
+// the path a file in trash is restored to
+func (c *MAClient) GetTrashPath(inode uint32) (path string, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_GETTRASHPATH, 0, inode)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 8)
+	if err != nil {
+		return
+	}
+	var length uint32
+	UnPack(buf[4:], &length)
+	if uint32(len(buf)) != length+8 {
+		err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+		return
+	}
+	path = string(buf[8:])
+	glog.V(8).Infof("trash path of %d: %s", inode, path)
+	return
+}
+
+func (c *MAClient) SetTrashPath(inode uint32, path string) (err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_SETTRASHPATH, 0, inode, uint32(len(path)), path)
+	if err != nil {
+		return
+	}
+	err = c.checkBuf(buf, 0, 5)
+	if err != nil {
+		return
+	}
+	err = getStatus(buf[4:])
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("set trash path of %d: %s", inode, path)
+	return
+}
+
+// all files in trash with their attrs
+func (m *MetaClient) Trash() (entries []*DetachedEntry, err error) {
+	entries, err = m.mc.GetTrash(TRASH_ID_ALL)
+	if err != nil {
+		return
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		e.Info, err = m.mc.GetDetachedAttr(e.Inode, DTYPE_TRASH)
+		if err == MFS_ERROR_ENOENT {
+			// purged after listing
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		kept = append(kept, e)
+	}
+	entries = kept
+	return
+}
+
+func (m *MetaClient) TrashPath(inode uint32) (string, error) {
+	return m.mc.GetTrashPath(inode)
+}
+
+// change the path a file in trash is restored to
+func (m *MetaClient) SetTrashPath(inode uint32, path string) error {
+	return m.mc.SetTrashPath(inode, path)
+}
+
+// restore a file from trash
+func (m *MetaClient) Undel(inode uint32) error {
+	return m.mc.Undel(inode)
+}
+
+// remove a file from trash permanently
+func (m *MetaClient) Purge(inode uint32) error {
+	return m.mc.Purge(inode)
+}
+
+func (m *MetaClient) Sustained() ([]*DetachedEntry, error) {
+	return m.mc.Sustained()
+}
+
+func (m *MetaClient) GetDetachedAttr(inode uint32, dtype uint8) (*FileInfo, error) {
+	return m.mc.GetDetachedAttr(inode, dtype)
+}
+
+func (m *MetaClient) Statfs() (*StatInfo, error) {
+	return m.mc.Statfs()
+}
@@ -0,0 +1,85 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestMetaRegister(t *testing.T) {
+	m := &MetaClient{mc: NewMAClientPwd("", "", false)}
+	buf := Pack(uint32(ParseVersionString("3.0.103")), uint32(12), uint64(34),
+		uint8(SESFLAG_ADMIN), uint8(1), uint8(9), uint32(0), uint32(86400))
+	if err := m.register(buf); err != nil {
+		t.Fatal(err)
+	}
+	if m.SessionId() != 12 || m.MetaId != 34 || m.Flags != SESFLAG_ADMIN ||
+		m.MinGoal != 1 || m.MaxGoal != 9 || m.MaxTrashTime != 86400 {
+		t.Error("unexpect", m)
+	}
+	if err := m.register([]byte{uint8(MFS_ERROR_BADPASSWORD)}); err != ErrAuthFailed {
+		t.Error("unexpect", err)
+	}
+	if err := m.register(buf[:20]); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestMetaClose(t *testing.T) {
+	conn, master := net.Pipe()
+	defer master.Close()
+	m := &MetaClient{mc: NewMAClientPwd("", "", false), MetaId: 34}
+	m.mc.conn = conn
+	m.mc.sessionId = 12
+	body := make(chan []byte, 1)
+	go func() {
+		hdr := make([]byte, 8)
+		if _, err := io.ReadFull(master, hdr); err != nil {
+			return
+		}
+		var cmd, size uint32
+		UnPack(hdr, &cmd, &size)
+		buf := make([]byte, size)
+		io.ReadFull(master, buf)
+		body <- buf
+		master.Write(Pack(cmd+1, uint32(1), uint8(0)))
+	}()
+	m.Close()
+	buf := <-body
+	// blob, rcode, sessionid and metaid
+	if len(buf) != len(FUSE_REGISTER_BLOB_ACL)+1+4+8 {
+		t.Fatal("unexpect size", len(buf))
+	}
+	var id uint32
+	var metaId uint64
+	UnPack(buf[len(buf)-12:], &id, &metaId)
+	if id != 12 || metaId != 34 {
+		t.Error("unexpect", id, metaId)
+	}
+}
+
+func TestMetaClient(t *testing.T) {
+	t.Skip()
+	m, err := NewMetaClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	entries, err := m.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		path, err := m.TrashPath(e.Inode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Log(e.Name, e.Inode, path)
+	}
+}
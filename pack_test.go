@@ -15,7 +15,6 @@ import (
 
 func init() {
 	flag.Set("logtostderr", "true")
-	flag.Parse()
 }
 
 func TestGlog(t *testing.T) {
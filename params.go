@@ -0,0 +1,201 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+)
+
+// same as the status of mfsmaster, so a session known to be read only
+// and an answer of mfsmaster can be checked in the same way
+var ErrReadOnly error = MFS_ERROR_EROFS
+
+// commands rejected in read only sessions, QUOTACONTROL and ARCHCTL
+// are checked by their callers because they also get
+var writeCmds = map[uint32]bool{
+	CLTOMA_FUSE_SETATTR:         true,
+	CLTOMA_FUSE_SYMLINK:         true,
+	CLTOMA_FUSE_MKNOD:           true,
+	CLTOMA_FUSE_MKDIR:           true,
+	CLTOMA_FUSE_UNLINK:          true,
+	CLTOMA_FUSE_RMDIR:           true,
+	CLTOMA_FUSE_RENAME:          true,
+	CLTOMA_FUSE_LINK:            true,
+	CLTOMA_FUSE_WRITE_CHUNK:     true,
+	CLTOMA_FUSE_WRITE_CHUNK_END: true,
+	CLTOMA_FUSE_SETTRASHTIME:    true,
+	CLTOMA_FUSE_SETSCLASS:       true,
+	CLTOMA_FUSE_SETTRASHPATH:    true,
+	CLTOMA_FUSE_UNDEL:           true,
+	CLTOMA_FUSE_PURGE:           true,
+	CLTOMA_FUSE_TRUNCATE:        true,
+	CLTOMA_FUSE_REPAIR:          true,
+	CLTOMA_FUSE_SNAPSHOT:        true,
+	CLTOMA_FUSE_SETEATTR:        true,
+	CLTOMA_FUSE_SETXATTR:        true,
+	CLTOMA_FUSE_CREATE:          true,
+	CLTOMA_FUSE_SETFACL:         true,
+}
+
+// the answer of REGISTER_NEWSESSION, options of mfsexports.cfg
+type SessionParams struct {
+	MetaId    uint64
+	Flags     uint8 // SESFLAG_*
+	RootUid   uint32
+	RootGid   uint32
+	MapallUid uint32
+	MapallGid uint32
+	SessionLimits
+}
+
+func (p *SessionParams) ReadOnly() bool {
+	return p.Flags&SESFLAG_READONLY != 0
+}
+
+func (p *SessionParams) MapAll() bool {
+	return p.Flags&SESFLAG_MAPALL != 0
+}
+
+// the 43 bytes answer of REGISTER_NEWSESSION
+func parseSessionParams(buf []byte) (p *SessionParams, id uint32) {
+	p = new(SessionParams)
+	var ver uint32
+	UnPack(buf, &ver, &id, &p.MetaId, &p.Flags, &p.RootUid, &p.RootGid,
+		&p.MapallUid, &p.MapallGid, &p.MinGoal, &p.MaxGoal,
+		&p.MinTrashTime, &p.MaxTrashTime)
+	return
+}
+
+// all requests use the mapall identity like mfsmaster does,
+// see identity
+func (c *MAClient) setParams(p *SessionParams) {
+	c.Params = p
+	if p.MapAll() {
+		glog.V(8).Infof("session mapall %d:%d", p.MapallUid, p.MapallGid)
+	}
+}
+
+func (c *MAClient) writable() error {
+	if c.Params != nil && c.Params.ReadOnly() {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+func (l *SessionLimits) checkGoal(goal uint8) error {
+	if goal < l.MinGoal || goal > l.MaxGoal {
+		return fmt.Errorf("goal %d is out of range %d-%d of session",
+			goal, l.MinGoal, l.MaxGoal)
+	}
+	return nil
+}
+
+func (l *SessionLimits) checkTrashTime(trashtime uint32) error {
+	if trashtime < l.MinTrashTime || trashtime > l.MaxTrashTime {
+		return fmt.Errorf("trashtime %d is out of range %d-%d of session",
+			trashtime, l.MinTrashTime, l.MaxTrashTime)
+	}
+	return nil
+}
+
+func (c *MAClient) setChange(buf []byte) (ci *ChangeInfo, err error) {
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 16)
+	if err != nil {
+		return
+	}
+	ci = new(ChangeInfo)
+	UnPack(buf[4:], &ci.Changed, &ci.NotChanged, &ci.NotPermitted)
+	if len(buf) >= 20 {
+		UnPack(buf[16:], &ci.QuotaExceeded)
+	}
+	return
+}
+
+// trashtime in seconds, see MAClient.SetEAttr for smode
+func (c *MAClient) SetTrashTime(inode, trashtime uint32, smode uint8) (ci *ChangeInfo, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if c.Params != nil {
+		if err = c.Params.checkTrashTime(trashtime); err != nil {
+			return
+		}
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_SETTRASHTIME, 0, inode, c.identity().Uid, trashtime, smode)
+	if err != nil {
+		return
+	}
+	ci, err = c.setChange(buf)
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("set trashtime inode %d %d changed %d", inode, trashtime, ci.Changed)
+	return
+}
+
+// set goal or storage class by name, see MAClient.SetEAttr for smode
+func (c *MAClient) SetSClass(inode uint32, sc *StorageClass, smode uint8) (ci *ChangeInfo, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if copies := sc.Copies(); c.Params != nil && copies > 0 {
+		if err = c.Params.checkGoal(uint8(copies)); err != nil {
+			return
+		}
+	}
+	var buf []byte
+	if sc.Goal == SCLASS_GOAL {
+		if err = checkInodeName(nil, &sc.Name); err != nil {
+			return
+		}
+		buf, err = c.doCmd(CLTOMA_FUSE_SETSCLASS, 0, inode, c.identity().Uid, sc.Goal, smode,
+			uint8(len(sc.Name)), sc.Name)
+	} else {
+		buf, err = c.doCmd(CLTOMA_FUSE_SETSCLASS, 0, inode, c.identity().Uid, sc.Goal, smode)
+	}
+	if err != nil {
+		return
+	}
+	ci, err = c.setChange(buf)
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("set sclass inode %d %s changed %d", inode, sc, ci.Changed)
+	return
+}
+
+// like mfssettrashtime
+func (c *Client) SetTrashTime(path string, trashtime uint32, smode uint8) (ci *ChangeInfo, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.SetTrashTime(info.Inode, trashtime, smode)
+}
+
+// like mfssetsclass and mfssetgoal
+func (c *Client) SetSClass(path string, sc *StorageClass, smode uint8) (ci *ChangeInfo, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.SetSClass(info.Inode, sc, smode)
+}
+
+// nil before the session is created
+func (c *Client) SessionParams() *SessionParams {
+	return c.mc.Params
+}
@@ -0,0 +1,61 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestParseSessionParams(t *testing.T) {
+	buf := Pack(uint32(ParseVersionString("3.0.103")), uint32(7), uint64(8),
+		uint8(SESFLAG_READONLY|SESFLAG_MAPALL), uint32(0), uint32(0),
+		uint32(1000), uint32(1001), uint8(2), uint8(3), uint32(60), uint32(3600))
+	if len(buf) != 43 {
+		t.Fatal("unexpect size", len(buf))
+	}
+	p, id := parseSessionParams(buf)
+	if id != 7 || p.MetaId != 8 || !p.ReadOnly() || !p.MapAll() ||
+		p.MapallUid != 1000 || p.MinGoal != 2 || p.MaxTrashTime != 3600 {
+		t.Error("unexpect", id, p)
+	}
+	c := NewMAClientPwd("", "", false)
+	c.setParams(p)
+	if cred := c.Credentials(); cred.Uid != 1000 || cred.Gid != 1001 {
+		t.Error("unexpect mapall", cred)
+	}
+	// rejected before sending
+	if _, err := c.Mkdir(MFS_ROOT_ID, "a", 0755); err != ErrReadOnly {
+		t.Error("unexpect", err)
+	}
+	if _, err := c.SetQuota(MFS_ROOT_ID, &Quota{}); err != ErrReadOnly {
+		t.Error("unexpect", err)
+	}
+	if _, err := c.SetTrashTime(MFS_ROOT_ID, 10, SMODE_SET); err == nil {
+		t.Error("expect trashtime error")
+	}
+	if _, err := c.SetTrashTime(MFS_ROOT_ID, 60, SMODE_SET); err != ErrReadOnly {
+		t.Error("unexpect", err)
+	}
+	if _, err := c.SetSClass(MFS_ROOT_ID, &StorageClass{Goal: 4}, SMODE_SET); err == nil {
+		t.Error("expect goal error")
+	}
+	if _, err := c.SetSClass(MFS_ROOT_ID, &StorageClass{Goal: SCLASS_GOAL, Name: "3"},
+		SMODE_SET); err != ErrReadOnly {
+		t.Error("unexpect", err)
+	}
+}
+
+func TestSessionParams(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	p := c.SessionParams()
+	t.Log(p.Flags, p.MinGoal, p.MaxGoal, p.MinTrashTime, p.MaxTrashTime)
+}
@@ -0,0 +1,264 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sort"
+	"strings"
+)
+
+// qflags of CLTOMA_FUSE_QUOTACONTROL, one for each limit
+const (
+	QUOTA_FLAG_SINODES   = 0x01
+	QUOTA_FLAG_SLENGTH   = 0x02
+	QUOTA_FLAG_SSIZE     = 0x04
+	QUOTA_FLAG_SREALSIZE = 0x08
+	QUOTA_FLAG_HINODES   = 0x10
+	QUOTA_FLAG_HLENGTH   = 0x20
+	QUOTA_FLAG_HSIZE     = 0x40
+	QUOTA_FLAG_HREALSIZE = 0x80
+	QUOTA_FLAG_ALL       = 0xFF
+)
+
+// names of quota flags, as in the options of mfssetquota
+var quotaFlagNames = []struct {
+	flag uint8
+	name string
+}{
+	{QUOTA_FLAG_SINODES, "sinodes"},
+	{QUOTA_FLAG_SLENGTH, "slength"},
+	{QUOTA_FLAG_SSIZE, "ssize"},
+	{QUOTA_FLAG_SREALSIZE, "srealsize"},
+	{QUOTA_FLAG_HINODES, "hinodes"},
+	{QUOTA_FLAG_HLENGTH, "hlength"},
+	{QUOTA_FLAG_HSIZE, "hsize"},
+	{QUOTA_FLAG_HREALSIZE, "hrealsize"},
+}
+
+// parse comma separated names like "sinodes,hlength" to quota flags
+func ParseQuotaFlags(str string) (flags uint8, err error) {
+	for _, n := range strings.Split(str, ",") {
+		n = strings.TrimSpace(n)
+		if len(n) == 0 {
+			continue
+		}
+		found := false
+		for _, f := range quotaFlagNames {
+			if f.name == n {
+				flags |= f.flag
+				found = true
+				break
+			}
+		}
+		if !found {
+			err = fmt.Errorf("unknown quota flag %s", n)
+			return
+		}
+	}
+	return
+}
+
+func QuotaFlagsStr(flags uint8) string {
+	names := make([]string, 0, len(quotaFlagNames))
+	for _, f := range quotaFlagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+type Quota struct {
+	Inode         uint32 `json:"inode"`
+	Path          string `json:"path,omitempty"`
+	Flags         uint8  `json:"flags"` // limits which are set
+	GracePeriod   uint32 `json:"grace_period"`
+	Exceeded      bool   `json:"exceeded"`
+	SoftTimestamp uint32 `json:"soft_timestamp,omitempty"` // soft limit exceeded since
+	SoftInodes    uint32 `json:"soft_inodes"`
+	SoftLength    uint64 `json:"soft_length"`
+	SoftSize      uint64 `json:"soft_size"`
+	SoftRealSize  uint64 `json:"soft_realsize"`
+	HardInodes    uint32 `json:"hard_inodes"`
+	HardLength    uint64 `json:"hard_length"`
+	HardSize      uint64 `json:"hard_size"`
+	HardRealSize  uint64 `json:"hard_realsize"`
+	CurrInodes    uint32 `json:"curr_inodes"`
+	CurrLength    uint64 `json:"curr_length"`
+	CurrSize      uint64 `json:"curr_size"`
+	CurrRealSize  uint64 `json:"curr_realsize"`
+}
+
+func (info *QuotaInfo) Quota() *Quota {
+	return &Quota{
+		Inode:         info.inode,
+		Path:          info.path,
+		Flags:         info.qflags,
+		GracePeriod:   info.graceperiod,
+		Exceeded:      info.exceeded != 0,
+		SoftTimestamp: info.stimestamp,
+		SoftInodes:    info.sinodes,
+		SoftLength:    info.slength,
+		SoftSize:      info.ssize,
+		SoftRealSize:  info.srealsize,
+		HardInodes:    info.hinodes,
+		HardLength:    info.hlength,
+		HardSize:      info.hsize,
+		HardRealSize:  info.hrealsize,
+		CurrInodes:    info.currinodes,
+		CurrLength:    info.currlength,
+		CurrSize:      info.currsize,
+		CurrRealSize:  info.currrealsize,
+	}
+}
+
+func (c *MAClient) quotaControl(inode uint32, args ...interface{}) (q *Quota, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_QUOTACONTROL, append([]interface{}{0, inode}, args...)...)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 93)
+	if err != nil {
+		return
+	}
+	q = &Quota{Inode: inode}
+	UnPack(buf[4:], &q.Flags, &q.GracePeriod, &q.SoftInodes, &q.SoftLength,
+		&q.SoftSize, &q.SoftRealSize, &q.HardInodes, &q.HardLength, &q.HardSize,
+		&q.HardRealSize, &q.CurrInodes, &q.CurrLength, &q.CurrSize, &q.CurrRealSize)
+	glog.V(8).Infof("quota control inode %d flags %s", inode, QuotaFlagsStr(q.Flags))
+	return
+}
+
+func (c *MAClient) GetQuota(inode uint32) (*Quota, error) {
+	return c.quotaControl(inode, uint8(0))
+}
+
+// set the limits selected by q.Flags, like mfssetquota
+func (c *MAClient) SetQuota(inode uint32, q *Quota) (*Quota, error) {
+	if err := c.writable(); err != nil {
+		return nil, err
+	}
+	return c.quotaControl(inode, q.Flags, q.GracePeriod, q.SoftInodes,
+		q.SoftLength, q.SoftSize, q.SoftRealSize, q.HardInodes, q.HardLength,
+		q.HardSize, q.HardRealSize)
+}
+
+// delete the limits selected by flags, like mfsdelquota
+func (c *MAClient) DeleteQuota(inode uint32, flags uint8) (*Quota, error) {
+	if err := c.writable(); err != nil {
+		return nil, err
+	}
+	return c.quotaControl(inode, flags)
+}
+
+func (c *Client) GetQuota(path string) (q *Quota, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	q, err = c.mc.GetQuota(info.Inode)
+	if q != nil {
+		q.Path = path
+	}
+	return
+}
+
+func (c *Client) SetQuota(path string, q *Quota) (nq *Quota, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	nq, err = c.mc.SetQuota(info.Inode, q)
+	if nq != nil {
+		nq.Path = path
+	}
+	return
+}
+
+// flags 0 deletes all limits
+func (c *Client) DeleteQuota(path string, flags uint8) (q *Quota, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	if flags == 0 {
+		flags = QUOTA_FLAG_ALL
+	}
+	q, err = c.mc.DeleteQuota(info.Inode, flags)
+	if q != nil {
+		q.Path = path
+	}
+	return
+}
+
+// all quotas of mfsmaster sorted by path, like mfsrepquota
+func (c *Client) Quotas() (quotas []*Quota, err error) {
+	tools, err := c.Tools()
+	if err != nil {
+		return
+	}
+	infos, err := tools.AllQuotaInfo()
+	if err != nil {
+		return
+	}
+	quotas = make([]*Quota, 0, len(infos))
+	for _, info := range infos {
+		quotas = append(quotas, info.Quota())
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		return quotas[i].Path < quotas[j].Path
+	})
+	return
+}
+
+// get the max retio, only care soft quota
+func (q *Quota) Usage() (current, quota string, retio float64) {
+	c := float64(q.CurrLength)
+	l := float64(q.SoftLength)
+	current = FormatBytes(c, Binary)
+	quota = FormatBytes(l, Binary)
+	if q.SoftLength != 0 {
+		r := c / l
+		if r >= retio {
+			retio = r
+		}
+	}
+	if q.SoftSize != 0 {
+		c := float64(q.CurrSize)
+		l := float64(q.SoftSize)
+		r := c / l
+		if r > retio {
+			retio = r
+			current = FormatBytes(c, Binary)
+			quota = FormatBytes(l, Binary)
+		}
+	}
+	if q.SoftInodes != 0 {
+		c := float64(q.CurrInodes)
+		l := float64(q.SoftInodes)
+		r := c / l
+		if r > retio {
+			retio = r
+			current = FormatBytes(c, Decimal)
+			quota = FormatBytes(l, Decimal)
+		}
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestQuotaFlags(t *testing.T) {
+	flags, err := ParseQuotaFlags("sinodes, hlength,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags != QUOTA_FLAG_SINODES|QUOTA_FLAG_HLENGTH {
+		t.Error("unexpect", flags)
+	}
+	if QuotaFlagsStr(flags) != "sinodes,hlength" {
+		t.Error("unexpect", QuotaFlagsStr(flags))
+	}
+	if QuotaFlagsStr(QUOTA_FLAG_ALL) != "sinodes,slength,ssize,srealsize,"+
+		"hinodes,hlength,hsize,hrealsize" {
+		t.Error("unexpect all")
+	}
+	if _, err = ParseQuotaFlags("size"); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestQuotaInfoQuota(t *testing.T) {
+	info := &QuotaInfo{inode: 13, path: "/a", exceeded: 1,
+		qflags: QUOTA_FLAG_SLENGTH, slength: 100, currlength: 200}
+	q := info.Quota()
+	if q.Inode != 13 || q.Path != "/a" || !q.Exceeded ||
+		q.SoftLength != 100 || q.CurrLength != 200 {
+		t.Error("unexpect", q)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	q, err := c.SetQuota("/", &Quota{Flags: QUOTA_FLAG_SLENGTH | QUOTA_FLAG_HINODES,
+		SoftLength: 1 << 40, HardInodes: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.SoftLength != 1<<40 || q.HardInodes != 1000 {
+		t.Error("unexpect", q)
+	}
+	q, err = c.DeleteQuota("/", QUOTA_FLAG_SLENGTH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Flags != QUOTA_FLAG_HINODES || q.SoftLength != 0 {
+		t.Error("unexpect", q)
+	}
+	if _, err = c.DeleteQuota("/", 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,293 @@
+package quotawatch
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+// poll quotas of mfsmaster and fire actions when the usage of a path
+// crosses thresholds, enters the grace period or recovers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	mfs "github.com/Hacky-DH/moosefs-client"
+	"github.com/golang/glog"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_INTERVAL = time.Minute
+	DEFAULT_WARNING  = 0.8
+	DEFAULT_CRITICAL = 0.95
+	WEBHOOK_TIMEOUT  = 10 * time.Second
+)
+
+type Level int
+
+const (
+	LevelOK       Level = iota
+	LevelWarning        // usage ratio >= warning
+	LevelCritical       // usage ratio >= critical
+	LevelGrace          // soft limit exceeded, in grace period
+	LevelExceeded       // hard limit exceeded or grace period is over
+)
+
+var levelNames = []string{"ok", "warning", "critical", "grace", "exceeded"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	for i, n := range levelNames {
+		if n == string(text) {
+			*l = Level(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown level %s", text)
+}
+
+type Alert struct {
+	Path      string    `json:"path"`
+	Level     Level     `json:"level"`
+	Previous  Level     `json:"previous"`
+	Recovered bool      `json:"recovered"`
+	Ratio     float64   `json:"ratio"`
+	Current   string    `json:"current"`
+	Quota     string    `json:"quota"`
+	Time      time.Time `json:"time"`
+}
+
+func (a *Alert) String() string {
+	if a.Recovered {
+		return fmt.Sprintf("quota of %s recovered from %s, usage %s of %s (%.2f%%)",
+			a.Path, a.Previous, a.Current, a.Quota, a.Ratio*100)
+	}
+	return fmt.Sprintf("quota of %s is %s, usage %s of %s (%.2f%%)",
+		a.Path, a.Level, a.Current, a.Quota, a.Ratio*100)
+}
+
+type Action interface {
+	Fire(ctx context.Context, a *Alert) error
+}
+
+// POST the alert in json
+type WebhookAction struct {
+	URL    string
+	Client *http.Client // http.DefaultClient with WEBHOOK_TIMEOUT if nil
+}
+
+func (w *WebhookAction) Fire(ctx context.Context, a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, WEBHOOK_TIMEOUT)
+	defer cancel()
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook %s got status %s", w.URL, resp.Status)
+	}
+	return nil
+}
+
+// run Command by sh with the alert in json on stdin and in
+// environment variables MFS_QUOTA_*
+type ExecAction struct {
+	Command string
+}
+
+func (e *ExecAction) Fire(ctx context.Context, a *Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", e.Command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"MFS_QUOTA_PATH="+a.Path,
+		"MFS_QUOTA_LEVEL="+a.Level.String(),
+		"MFS_QUOTA_PREVIOUS="+a.Previous.String(),
+		fmt.Sprintf("MFS_QUOTA_RECOVERED=%t", a.Recovered),
+		fmt.Sprintf("MFS_QUOTA_RATIO=%.4f", a.Ratio))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("exec %s error %v: %s", e.Command, err, out)
+	}
+	return nil
+}
+
+// write the alert to the log
+type LogAction struct{}
+
+func (LogAction) Fire(_ context.Context, a *Alert) error {
+	if a.Recovered {
+		glog.Info(a)
+	} else {
+		glog.Warning(a)
+	}
+	return nil
+}
+
+type pathState struct {
+	level Level
+	fired time.Time
+}
+
+type Watcher struct {
+	Source   func() ([]*mfs.Quota, error)
+	Actions  []Action
+	Warning  float64       // ratio of soft quota
+	Critical float64       // ratio of soft quota
+	Interval time.Duration // between polls
+	Repeat   time.Duration // fire the same level again after, 0 means never
+	mu       sync.Mutex
+	state    map[string]*pathState
+}
+
+// source is usually Client.Quotas
+func New(source func() ([]*mfs.Quota, error), actions ...Action) *Watcher {
+	return &Watcher{
+		Source:   source,
+		Actions:  actions,
+		Warning:  DEFAULT_WARNING,
+		Critical: DEFAULT_CRITICAL,
+		Interval: DEFAULT_INTERVAL,
+		state:    make(map[string]*pathState),
+	}
+}
+
+func (w *Watcher) level(q *mfs.Quota, ratio float64) Level {
+	switch {
+	case q.Exceeded:
+		return LevelExceeded
+	case q.SoftTimestamp != 0:
+		return LevelGrace
+	case ratio >= w.Critical:
+		return LevelCritical
+	case ratio >= w.Warning:
+		return LevelWarning
+	}
+	return LevelOK
+}
+
+// fire all actions, the alert is sent again in the next poll if any fails
+func (w *Watcher) fire(ctx context.Context, a *Alert) (err error) {
+	for _, act := range w.Actions {
+		if e := act.Fire(ctx, a); e != nil {
+			glog.Errorf("fire alert of %s error %v", a.Path, e)
+			err = e
+		}
+	}
+	return
+}
+
+// poll once, alert on level changes and recoveries, paths which
+// have no quota any more are recovered
+func (w *Watcher) Check(ctx context.Context) (err error) {
+	quotas, err := w.Source()
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.state == nil {
+		w.state = make(map[string]*pathState)
+	}
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, q := range quotas {
+		seen[q.Path] = true
+		current, quota, ratio := q.Usage()
+		a := &Alert{
+			Path:    q.Path,
+			Ratio:   ratio,
+			Current: current,
+			Quota:   quota,
+			Time:    now,
+		}
+		a.Level = w.level(q, ratio)
+		st := w.state[q.Path]
+		if st == nil {
+			st = &pathState{level: LevelOK}
+		}
+		a.Previous = st.level
+		a.Recovered = a.Level == LevelOK && st.level != LevelOK
+		repeat := a.Level != LevelOK && w.Repeat > 0 && now.Sub(st.fired) >= w.Repeat
+		if a.Level == st.level && !repeat {
+			continue
+		}
+		if e := w.fire(ctx, a); e != nil {
+			err = e
+			continue
+		}
+		if a.Level == LevelOK {
+			delete(w.state, q.Path)
+			continue
+		}
+		w.state[q.Path] = &pathState{level: a.Level, fired: now}
+	}
+	for path, st := range w.state {
+		if seen[path] {
+			continue
+		}
+		a := &Alert{Path: path, Level: LevelOK, Previous: st.level,
+			Recovered: true, Time: now}
+		if e := w.fire(ctx, a); e != nil {
+			err = e
+			continue
+		}
+		delete(w.state, path)
+	}
+	return
+}
+
+// poll every Interval until ctx is done
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DEFAULT_INTERVAL
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Check(ctx); err != nil {
+			glog.Errorf("check quota error %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package quotawatch
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"context"
+	"encoding/json"
+	mfs "github.com/Hacky-DH/moosefs-client"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	alerts []*Alert
+}
+
+func (r *recorder) Fire(_ context.Context, a *Alert) error {
+	r.alerts = append(r.alerts, a)
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	q := &mfs.Quota{Path: "/a", SoftLength: 100}
+	quotas := []*mfs.Quota{q}
+	rec := new(recorder)
+	w := New(func() ([]*mfs.Quota, error) { return quotas, nil }, rec)
+	check := func(length uint64, expect ...Level) {
+		t.Helper()
+		q.CurrLength = length
+		rec.alerts = nil
+		if err := w.Check(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if len(rec.alerts) != len(expect) {
+			t.Fatalf("unexpect alerts %d!=%d", len(rec.alerts), len(expect))
+		}
+		for i, l := range expect {
+			if rec.alerts[i].Level != l {
+				t.Errorf("unexpect level %s!=%s", rec.alerts[i].Level, l)
+			}
+		}
+	}
+	check(10)
+	check(85, LevelWarning)
+	check(86)
+	check(96, LevelCritical)
+	q.SoftTimestamp = 1
+	check(120, LevelGrace)
+	q.Exceeded = true
+	check(130, LevelExceeded)
+	q.Exceeded = false
+	q.SoftTimestamp = 0
+	check(10, LevelOK)
+	if !rec.alerts[0].Recovered || rec.alerts[0].Previous != LevelExceeded {
+		t.Error("unexpect recovery", rec.alerts[0])
+	}
+	check(10)
+	// repeat
+	w.Repeat = time.Nanosecond
+	check(90, LevelWarning)
+	check(90, LevelWarning)
+	// quota deleted
+	quotas = nil
+	rec.alerts = nil
+	if err := w.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.alerts) != 1 || !rec.alerts[0].Recovered || rec.alerts[0].Path != "/a" {
+		t.Error("unexpect", rec.alerts)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var got []*Alert
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		a := new(Alert)
+		if err := json.NewDecoder(r.Body).Decode(a); err != nil {
+			t.Error(err)
+		}
+		got = append(got, a)
+	}))
+	defer srv.Close()
+	q := &mfs.Quota{Path: "/b", SoftInodes: 10, CurrInodes: 9}
+	w := New(func() ([]*mfs.Quota, error) { return []*mfs.Quota{q}, nil },
+		&WebhookAction{URL: srv.URL})
+	ctx := context.Background()
+	if err := w.Check(ctx); err == nil {
+		t.Error("expect webhook error")
+	}
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	// retried in the next poll
+	if err := w.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Path != "/b" || got[0].Level != LevelWarning {
+		t.Fatal("unexpect", got)
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quotawatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	e := &ExecAction{Command: "echo $MFS_QUOTA_PATH $MFS_QUOTA_LEVEL > " + out}
+	err = e.Fire(context.Background(), &Alert{Path: "/c", Level: LevelCritical})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(buf)) != "/c critical" {
+		t.Error("unexpect", string(buf))
+	}
+	e = &ExecAction{Command: "exit 1"}
+	if err = e.Fire(context.Background(), &Alert{Path: "/c"}); err == nil {
+		t.Error("expect error")
+	}
+}
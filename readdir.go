@@ -0,0 +1,171 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+)
+
+// flags of CLTOMA_FUSE_READDIR
+const (
+	GETDIR_FLAG_WITHATTR   = 0x01
+	GETDIR_FLAG_ADDTOCACHE = 0x02
+)
+
+const (
+	// max entries of each readdir request
+	READDIR_PAGE_SIZE = 0x2000
+	// nedgeid of the last page
+	EDGEID_MAX = 0x7FFFFFFFFFFFFFFF
+)
+
+// an entry of directory, Info is nil without attributes
+type DirEntry struct {
+	Name  string
+	Inode uint32
+	Type  uint8 // TYPE_*
+	Info  *FileInfo
+}
+
+// called for each entry in the order of mfsmaster including . and ..,
+// return StopReaddir to stop without error
+type ReaddirFunc func(e *DirEntry) error
+
+var StopReaddir = errors.New("stop readdir")
+
+// entries of a readdir answer after msgid and nedgeid
+func parseDirEntries(buf []byte, withAttr bool) (entries []*DirEntry, err error) {
+	pos := 0
+	for pos < len(buf) {
+		e := new(DirEntry)
+		sz := int(buf[pos])
+		pos++
+		if pos+sz+4 > len(buf) {
+			err = fmt.Errorf("readdir entry at %d is truncated", pos)
+			return
+		}
+		e.Name = string(buf[pos : pos+sz])
+		pos += sz
+		UnPack(buf[pos:], &e.Inode)
+		pos += 4
+		if withAttr {
+			var n uint32
+			n, e.Info, err = parseFileInfo(e.Inode, buf[pos:])
+			if err != nil {
+				return
+			}
+			e.Type = e.Info.Type
+			pos += int(n)
+		} else {
+			if pos >= len(buf) {
+				err = fmt.Errorf("readdir entry %s is truncated", e.Name)
+				return
+			}
+			e.Type = buf[pos]
+			pos++
+		}
+		entries = append(entries, e)
+	}
+	return
+}
+
+// at most max entries after edge, next is the edge of the next page
+func (c *MAClient) readdirPage(parent uint32, withAttr bool, max uint32,
+	edge uint64) (entries []*DirEntry, next uint64, err error) {
+	flags := uint8(0)
+	if withAttr {
+		flags = GETDIR_FLAG_WITHATTR
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_READDIR, 0, parent, c.ids(), flags,
+		max, edge)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 12)
+	if err != nil {
+		return
+	}
+	UnPack(buf[4:], &next)
+	entries, err = parseDirEntries(buf[12:], withAttr)
+	glog.V(10).Infof("readdir parent %d edge %d next %d len %d",
+		parent, edge, next, len(entries))
+	return
+}
+
+// call fn for every entry of parent, the directory is read by pages of
+// READDIR_PAGE_SIZE entries, so it is not limited by the size of answer
+func (c *MAClient) ReaddirEach(parent uint32, withAttr bool,
+	fn ReaddirFunc) (err error) {
+	if err = checkInodeName(&parent, nil); err != nil {
+		return
+	}
+	return c.readdirEach(parent, withAttr, READDIR_PAGE_SIZE, fn)
+}
+
+func (c *MAClient) readdirEach(parent uint32, withAttr bool, max uint32,
+	fn ReaddirFunc) (err error) {
+	var edge uint64
+	total := 0
+	for {
+		entries, next, e := c.readdirPage(parent, withAttr, max, edge)
+		if e != nil {
+			return e
+		}
+		for _, entry := range entries {
+			if err = fn(entry); err != nil {
+				if err == StopReaddir {
+					err = nil
+				}
+				return
+			}
+		}
+		total += len(entries)
+		// the whole directory, or mfsmaster does not page
+		if uint32(len(entries)) < max || next == 0 || next == EDGEID_MAX ||
+			next == edge {
+			break
+		}
+		edge = next
+	}
+	glog.V(8).Infof("readdir parent %d len %d", parent, total)
+	return
+}
+
+// all entries of parent in the order of mfsmaster
+func (c *MAClient) ReaddirList(parent uint32, withAttr bool) (entries []*DirEntry, err error) {
+	err = c.ReaddirEach(parent, withAttr, func(e *DirEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	return
+}
+
+func (c *Client) ReaddirEach(path string, withAttr bool, fn ReaddirFunc) (err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.ReaddirEach(info.Inode, withAttr, fn)
+}
+
+func (c *Client) ReaddirList(path string, withAttr bool) (entries []*DirEntry, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.ReaddirList(info.Inode, withAttr)
+}
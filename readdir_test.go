@@ -0,0 +1,70 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestParseDirEntries(t *testing.T) {
+	// two names of a hard link keep their order
+	buf := Pack(uint8(1), ".", uint32(1), uint8(TYPE_DIRECTORY),
+		uint8(1), "b", uint32(5), uint8(TYPE_FILE),
+		uint8(1), "a", uint32(5), uint8(TYPE_FILE))
+	entries, err := parseDirEntries(buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1].Name != "b" || entries[2].Name != "a" ||
+		entries[2].Inode != 5 || entries[0].Type != TYPE_DIRECTORY ||
+		entries[1].Info != nil {
+		t.Error("unexpect", entries)
+	}
+	if entries, err = parseDirEntries(nil, false); err != nil || len(entries) != 0 {
+		t.Error("unexpect", entries, err)
+	}
+	for _, b := range [][]byte{buf[:len(buf)-1], buf[:len(buf)-3], {9, 'a'}} {
+		if _, err = parseDirEntries(b, false); err == nil {
+			t.Error("expect error", b)
+		}
+	}
+	if _, err = parseDirEntries(buf, true); err == nil {
+		t.Error("expect error of short attr")
+	}
+}
+
+func TestReaddirEach(t *testing.T) {
+	t.Skip()
+	session(t, func(c *MAClient) {
+		n := 0
+		err := c.readdirEach(MFS_ROOT_ID, true, 2, func(e *DirEntry) error {
+			if e.Info == nil || e.Type != e.Info.Type {
+				t.Error("unexpect", e)
+			}
+			n++
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		all, err := c.ReaddirList(MFS_ROOT_ID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != n {
+			t.Error("unexpect", len(all), n)
+		}
+		n = 0
+		err = c.ReaddirEach(MFS_ROOT_ID, false, func(e *DirEntry) error {
+			n++
+			return StopReaddir
+		})
+		if err != nil || n != 1 {
+			t.Error("unexpect", n, err)
+		}
+	})
+}
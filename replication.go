@@ -0,0 +1,206 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"strconv"
+)
+
+// goal of storage class in GETSCLASS
+const SCLASS_GOAL = 0xFF
+
+// goal or storage class of an inode
+type StorageClass struct {
+	Goal uint8 // SCLASS_GOAL if Name is a storage class
+	Name string
+}
+
+// required copies, predefined classes are named by their goal,
+// 0 if unknown
+func (s *StorageClass) Copies() int {
+	if s.Goal != SCLASS_GOAL {
+		return int(s.Goal)
+	}
+	n, err := strconv.Atoi(s.Name)
+	if err != nil || n < 1 || n > 9 {
+		return 0
+	}
+	return n
+}
+
+func (s *StorageClass) String() string {
+	if s.Goal != SCLASS_GOAL {
+		return strconv.Itoa(int(s.Goal))
+	}
+	return s.Name
+}
+
+// storage class of inode itself, like mfsgetsclass without -r
+func (c *MAClient) GetSClass(inode uint32) (sc *StorageClass, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_GETSCLASS, 0, inode, uint8(GMODE_NORMAL))
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 11)
+	if err != nil {
+		return
+	}
+	var gdirs, gfiles uint8
+	UnPack(buf[4:], &gdirs, &gfiles)
+	if int(gdirs)+int(gfiles) != 1 {
+		err = fmt.Errorf("got wrong sclass count %d from mfsmaster",
+			int(gdirs)+int(gfiles))
+		return
+	}
+	sc = new(StorageClass)
+	pos := 6
+	UnPack(buf[pos:], &sc.Goal)
+	pos++
+	if sc.Goal == SCLASS_GOAL {
+		sz := int(buf[pos])
+		pos++
+		if pos+sz+4 > len(buf) {
+			err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+			return
+		}
+		sc.Name = string(buf[pos : pos+sz])
+	}
+	glog.V(8).Infof("get sclass inode %d goal %d name %s", inode, sc.Goal, sc.Name)
+	return
+}
+
+func (c *Client) GetSClass(path string) (sc *StorageClass, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.GetSClass(info.Inode)
+}
+
+// a file with missing or undergoal chunks
+type FileReplication struct {
+	Path      string                       `json:"path"`
+	Inode     uint32                       `json:"inode"`
+	SClass    string                       `json:"sclass"`
+	Goal      int                          `json:"goal"`
+	Chunks    uint32                       `json:"chunks"`
+	Missing   uint32                       `json:"missing"`
+	Undergoal uint32                       `json:"undergoal"`
+	Copies    [CHECK_MAX_COPIES + 1]uint32 `json:"copies"`
+}
+
+type ReplicationReport struct {
+	Path      string                       `json:"path"`
+	Files     uint64                       `json:"files"`
+	Chunks    uint64                       `json:"chunks"`
+	Missing   uint64                       `json:"missing"`
+	Undergoal uint64                       `json:"undergoal"`
+	Copies    [CHECK_MAX_COPIES + 1]uint64 `json:"copies"`
+	AtRisk    []*FileReplication           `json:"at_risk"`
+}
+
+// walk path and find files with chunks having less copies than
+// their storage class requires, goal is used for storage classes
+// whose copies count is unknown
+func (c *Client) ScanReplication(path string, goal int) (report *ReplicationReport, err error) {
+	report = &ReplicationReport{
+		Path:   path,
+		AtRisk: make([]*FileReplication, 0),
+	}
+	err = c.Walk(path, func(p string, info *FileInfo) (err error) {
+		if !info.IsFile() {
+			return
+		}
+		fc, err := c.mc.CheckFile(info.Inode)
+		if err != nil {
+			return
+		}
+		sc, err := c.mc.GetSClass(info.Inode)
+		if err != nil {
+			return
+		}
+		fr := &FileReplication{
+			Path:   p,
+			Inode:  info.Inode,
+			SClass: sc.String(),
+			Goal:   sc.Copies(),
+			Chunks: fc.Chunks(),
+			Copies: fc.Copies,
+		}
+		if fr.Goal == 0 {
+			fr.Goal = goal
+		}
+		report.Files++
+		report.Chunks += uint64(fc.Chunks())
+		for n, cnt := range fc.Copies {
+			report.Copies[n] += uint64(cnt)
+			if n == 0 {
+				fr.Missing += cnt
+			} else if n < fr.Goal {
+				fr.Undergoal += cnt
+			}
+		}
+		report.Missing += uint64(fr.Missing)
+		report.Undergoal += uint64(fr.Undergoal)
+		if fr.Missing > 0 || fr.Undergoal > 0 {
+			glog.V(5).Infof("%s missing %d undergoal %d", p, fr.Missing, fr.Undergoal)
+			report.AtRisk = append(report.AtRisk, fr)
+		}
+		return
+	})
+	return
+}
+
+func (r *ReplicationReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// one line for each file at risk
+func (r *ReplicationReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	head := []string{"path", "inode", "sclass", "goal", "chunks", "missing", "undergoal"}
+	for n := 0; n < CHECK_MAX_COPIES; n++ {
+		head = append(head, fmt.Sprintf("copies%d", n))
+	}
+	head = append(head, fmt.Sprintf("copies%d+", CHECK_MAX_COPIES))
+	if err := cw.Write(head); err != nil {
+		return err
+	}
+	for _, fr := range r.AtRisk {
+		rec := []string{fr.Path, strconv.FormatUint(uint64(fr.Inode), 10),
+			fr.SClass, strconv.Itoa(fr.Goal),
+			strconv.FormatUint(uint64(fr.Chunks), 10),
+			strconv.FormatUint(uint64(fr.Missing), 10),
+			strconv.FormatUint(uint64(fr.Undergoal), 10)}
+		for _, cnt := range fr.Copies {
+			rec = append(rec, strconv.FormatUint(uint64(cnt), 10))
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
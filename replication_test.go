@@ -0,0 +1,72 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestStorageClassCopies(t *testing.T) {
+	for _, c := range []struct {
+		sc     StorageClass
+		copies int
+		str    string
+	}{
+		{StorageClass{Goal: 2}, 2, "2"},
+		{StorageClass{Goal: SCLASS_GOAL, Name: "3"}, 3, "3"},
+		{StorageClass{Goal: SCLASS_GOAL, Name: "ssd"}, 0, "ssd"},
+	} {
+		if c.sc.Copies() != c.copies || c.sc.String() != c.str {
+			t.Error("unexpect", c.sc)
+		}
+	}
+}
+
+func TestReplicationReportWrite(t *testing.T) {
+	fr := &FileReplication{Path: "/a,b", Inode: 10, SClass: "2", Goal: 2,
+		Chunks: 3, Missing: 1, Undergoal: 1}
+	fr.Copies[0], fr.Copies[1], fr.Copies[2] = 1, 1, 1
+	r := &ReplicationReport{Path: "/", Files: 1, Chunks: 3, Missing: 1,
+		Undergoal: 1, AtRisk: []*FileReplication{fr}}
+	var buf bytes.Buffer
+	if err := r.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], `"/a,b",10,2,2,3,1,1,1,1,1,0`) {
+		t.Error("unexpect csv", lines)
+	}
+	buf.Reset()
+	if err := r.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var out ReplicationReport
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.AtRisk) != 1 || out.AtRisk[0].Path != fr.Path || out.Missing != 1 {
+		t.Error("unexpect json", buf.String())
+	}
+}
+
+func TestScanReplication(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	report, err := c.ScanReplication("/", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.WriteCSV(os.Stdout)
+}
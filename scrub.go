@@ -0,0 +1,325 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"sync"
+	"time"
+)
+
+type RepairInfo struct {
+	NotChanged uint32
+	Erased     uint32
+	Repaired   uint32
+}
+
+func (c *MAClient) Repair(inode uint32) (ri *RepairInfo, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_REPAIR, 0, inode, c.ids())
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 16)
+	if err != nil {
+		return
+	}
+	ri = new(RepairInfo)
+	UnPack(buf[4:], &ri.NotChanged, &ri.Erased, &ri.Repaired)
+	glog.V(8).Infof("repair inode %d notchanged %d erased %d repaired %d",
+		inode, ri.NotChanged, ri.Erased, ri.Repaired)
+	return
+}
+
+// like mfsfilerepair
+func (c *Client) Repair(path string) (ri *RepairInfo, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.Repair(info.Inode)
+}
+
+// bytes per second shared by all workers, 0 means unlimited
+type rateLimiter struct {
+	rate uint64
+	next time.Time
+	sync.Mutex
+}
+
+// wait until n bytes are allowed or ctx is done
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil || l.rate == 0 {
+		return nil
+	}
+	l.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(time.Duration(uint64(n) * uint64(time.Second) / l.rate))
+	l.Unlock()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(at)):
+		return nil
+	}
+}
+
+// size of each read request in scrub
+const SCRUB_READ_SIZE = 16 * MFSBLOCKSIZE
+
+// read the first length bytes of chunk d from one chunkserver,
+// ReadBlock checks crc of every block
+func (d *CSData) scrubReplica(ctx context.Context, t *CSItem, length uint32,
+	limit *rateLimiter) (block uint16, err error) {
+	c, err := _cspool.Get(t)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			c.Close()
+		} else {
+			_cspool.Put(c)
+		}
+	}()
+	buf := make([]byte, SCRUB_READ_SIZE)
+	for off := uint32(0); off < length; off += SCRUB_READ_SIZE {
+		sz := length - off
+		if sz > SCRUB_READ_SIZE {
+			sz = SCRUB_READ_SIZE
+		}
+		if err = limit.wait(ctx, int(sz)); err != nil {
+			return
+		}
+		msg := PackCmd(CLTOCS_READ, d.ProtocolId, d.ChunkId, d.Version, off, sz)
+		if err = c.Send(msg); err != nil {
+			err = fmt.Errorf("send read to cs error %v", err)
+			return
+		}
+		for pos := uint32(0); pos < sz; pos += MFSBLOCKSIZE {
+			block = uint16((off + pos) >> MFSBLOCKBITS)
+			bsz := sz - pos
+			if bsz > MFSBLOCKSIZE {
+				bsz = MFSBLOCKSIZE
+			}
+			var n uint32
+			n, err = d.ReadBlock(c, buf[pos:pos+bsz], uint64(off+pos))
+			if err != nil {
+				return
+			}
+			if n != bsz {
+				err = fmt.Errorf("read block got status before data")
+				return
+			}
+		}
+		// the status after all data
+		if _, err = d.ReadBlock(c, buf, uint64(off)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+type ScrubOptions struct {
+	Workers     int    // files scrubbed in parallel, 1 by default
+	BytesPerSec uint64 // total read bandwidth, 0 means unlimited
+	Repair      bool   // repair files with bad chunks
+}
+
+// a chunk without valid copies, a copy which can not be read,
+// or a file which mfsmaster refused to check, read or repair
+type ScrubError struct {
+	Path    string
+	Index   uint32
+	ChunkId uint64
+	Version uint32
+	Replica string // empty if the chunk has no copies or for a file error
+	Block   uint16
+	Err     error
+}
+
+type ScrubReport struct {
+	Files      uint64
+	Chunks     uint64
+	Bytes      uint64 // read from all copies
+	Errors     []*ScrubError
+	Repaired   map[string]*RepairInfo
+	NotChanged uint32
+	Erased     uint32
+	Fixed      uint32 // repaired chunks
+	sync.Mutex
+}
+
+func (r *ScrubReport) add(e *ScrubError) {
+	r.Lock()
+	r.Errors = append(r.Errors, e)
+	r.Unlock()
+	glog.V(5).Infof("scrub %s chunk %d copy %s block %d error %v",
+		e.Path, e.Index, e.Replica, e.Block, e.Err)
+}
+
+// a status of mfsmaster about one file, e.g. removed while walking,
+// other errors mean the connection to mfsmaster is lost
+func isFileError(err error) bool {
+	_, ok := err.(MFSError)
+	return ok
+}
+
+// read every block of every copy of file, return true if all are fine
+func (c *Client) scrubFile(ctx context.Context, path string, info *FileInfo,
+	report *ScrubReport, limit *rateLimiter) (ok bool, err error) {
+	chunks, err := c.mc.FileChunks(info.Inode, info.Size)
+	if err != nil {
+		return
+	}
+	ok = true
+	for _, ci := range chunks {
+		if ci.IsEmpty() {
+			continue
+		}
+		report.Lock()
+		report.Chunks++
+		report.Unlock()
+		if ci.ValidCopies() == 0 {
+			ok = false
+			report.add(&ScrubError{Path: path, Index: ci.Index,
+				ChunkId: ci.ChunkId, Version: ci.Version,
+				Err: fmt.Errorf("no valid copies")})
+			continue
+		}
+		var cs *CSData
+		cs, err = c.mc.ReadChunk(info.Inode, ci.Index, 0)
+		if err != nil {
+			return
+		}
+		length := uint32(MFSCHUNKSIZE)
+		if rest := info.Size - uint64(ci.Index)<<MFSCHUNKBITS; rest < MFSCHUNKSIZE {
+			length = uint32(rest)
+		}
+		for _, r := range ci.Replicas {
+			block, e := cs.scrubReplica(ctx, &CSItem{Ip: r.Ip, Port: r.Port},
+				length, limit)
+			if ctx.Err() != nil {
+				err = ctx.Err()
+				return
+			}
+			if e != nil {
+				ok = false
+				report.add(&ScrubError{Path: path, Index: ci.Index,
+					ChunkId: ci.ChunkId, Version: ci.Version,
+					Replica: r.Addr(), Block: block, Err: e})
+				continue
+			}
+			report.Lock()
+			report.Bytes += uint64(length)
+			report.Unlock()
+		}
+	}
+	return
+}
+
+// walk path, read every block of every copy of all files and
+// optionally repair files with bad chunks, like mfsfilerepair
+func (c *Client) Scrub(ctx context.Context, path string,
+	opt *ScrubOptions) (report *ScrubReport, err error) {
+	if opt == nil {
+		opt = new(ScrubOptions)
+	}
+	workers := opt.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	limit := &rateLimiter{rate: opt.BytesPerSec}
+	report = &ScrubReport{
+		Errors:   make([]*ScrubError, 0),
+		Repaired: make(map[string]*RepairInfo),
+	}
+	type job struct {
+		path string
+		info *FileInfo
+	}
+	jobs := make(chan job)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				ok, e := c.scrubFile(ctx, j.path, j.info, report, limit)
+				if e == nil && !ok && opt.Repair {
+					e = c.repair(j.path, j.info, report)
+				}
+				if e != nil && ctx.Err() == nil && isFileError(e) {
+					report.add(&ScrubError{Path: j.path, Err: e})
+					continue
+				}
+				if e != nil {
+					errs <- e
+					return
+				}
+			}
+		}()
+	}
+	err = c.Walk(path, func(p string, info *FileInfo) error {
+		if !info.IsFile() {
+			return nil
+		}
+		report.Lock()
+		report.Files++
+		report.Unlock()
+		select {
+		case jobs <- job{p, info}:
+			return nil
+		case e := <-errs:
+			return e
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+	if err == nil {
+		select {
+		case err = <-errs:
+		default:
+		}
+	}
+	glog.V(5).Infof("scrub %s files %d chunks %d errors %d",
+		path, report.Files, report.Chunks, len(report.Errors))
+	return
+}
+
+func (c *Client) repair(path string, info *FileInfo, report *ScrubReport) (err error) {
+	ri, err := c.mc.Repair(info.Inode)
+	if err != nil {
+		return
+	}
+	report.Lock()
+	defer report.Unlock()
+	report.Repaired[path] = ri
+	report.NotChanged += ri.NotChanged
+	report.Erased += ri.Erased
+	report.Fixed += ri.Repaired
+	return
+}
@@ -0,0 +1,64 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	var l *rateLimiter
+	if err := l.wait(ctx, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	l = &rateLimiter{rate: 1 << 20}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(ctx, 1<<18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Error("unexpect too fast", d)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.wait(ctx, 1<<30); err != context.Canceled {
+		t.Error("unexpect", err)
+	}
+}
+
+func TestIsFileError(t *testing.T) {
+	if !isFileError(MFS_ERROR_ENOENT) {
+		t.Error("unexpect")
+	}
+	if isFileError(fmt.Errorf("cmd recv error EOF")) || isFileError(context.Canceled) {
+		t.Error("unexpect")
+	}
+}
+
+func TestScrub(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	report, err := c.Scrub(context.Background(), "/",
+		&ScrubOptions{Workers: 4, BytesPerSec: 100 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range report.Errors {
+		t.Log(e.Path, e.Index, e.Replica, e.Err)
+	}
+	t.Log(report.Files, report.Chunks, report.Bytes)
+}
@@ -0,0 +1,164 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+)
+
+// session flags
+const (
+	SESFLAG_READONLY          = 0x01
+	SESFLAG_DYNAMICIP         = 0x02
+	SESFLAG_IGNOREGID         = 0x04
+	SESFLAG_ADMIN             = 0x08
+	SESFLAG_MAPALL            = 0x10
+	SESFLAG_NOMASTERPERMCHECK = 0x20
+	SESFLAG_NONROOTMETA       = 0x40
+	SESFLAG_ATTRBIT           = 0x80
+)
+
+// names of the per operation stats of a session
+var SessionStatNames = []string{"statfs", "getattr", "setattr", "lookup",
+	"mkdir", "rmdir", "symlink", "readlink", "mknod", "unlink", "rename",
+	"link", "readdir", "open", "read", "write"}
+
+type SessionInfo struct {
+	Id           uint32   `json:"id"`
+	Ip           uint32   `json:"ip"`
+	Version      Version  `json:"version"`
+	OpenFiles    uint32   `json:"open_files"`
+	NSocks       uint8    `json:"nsocks"`
+	Expire       uint32   `json:"expire"`
+	Info         string   `json:"info"`
+	Path         string   `json:"path"`
+	Flags        uint8    `json:"flags"`
+	RootUid      uint32   `json:"root_uid"`
+	RootGid      uint32   `json:"root_gid"`
+	MapallUid    uint32   `json:"mapall_uid"`
+	MapallGid    uint32   `json:"mapall_gid"`
+	MinGoal      uint8    `json:"min_goal"`
+	MaxGoal      uint8    `json:"max_goal"`
+	MinTrashTime uint32   `json:"min_trashtime"`
+	MaxTrashTime uint32   `json:"max_trashtime"`
+	Current      []uint32 `json:"current"` // stats of the current hour
+	Last         []uint32 `json:"last"`    // stats of the last hour
+}
+
+func (s *SessionInfo) IpStr() string {
+	return ipStr(s.Ip)
+}
+
+// like the options of mfsexports.cfg
+func (s *SessionInfo) FlagsStr() string {
+	str := "rw"
+	if s.Flags&SESFLAG_READONLY != 0 {
+		str = "ro"
+	}
+	for _, f := range []struct {
+		flag uint8
+		name string
+	}{
+		{SESFLAG_DYNAMICIP, "dynamicip"},
+		{SESFLAG_IGNOREGID, "ignoregid"},
+		{SESFLAG_ADMIN, "admin"},
+		{SESFLAG_NOMASTERPERMCHECK, "nomasterpermcheck"},
+		{SESFLAG_NONROOTMETA, "nonrootmeta"},
+	} {
+		if s.Flags&f.flag != 0 {
+			str += "," + f.name
+		}
+	}
+	if s.Flags&SESFLAG_MAPALL != 0 {
+		str += fmt.Sprintf(",mapall=%d:%d", s.MapallUid, s.MapallGid)
+	} else {
+		str += fmt.Sprintf(",maproot=%d:%d", s.RootUid, s.RootGid)
+	}
+	return str
+}
+
+// the vmode=2 format of CLTOMA_SESSION_LIST
+func parseSessions(buf []byte) (sessions []*SessionInfo, err error) {
+	sessions = make([]*SessionInfo, 0)
+	if len(buf) < 2 {
+		return
+	}
+	var stats uint16
+	UnPack(buf, &stats)
+	short := func(pos, n int) bool {
+		if pos+n > len(buf) {
+			err = fmt.Errorf("list session got wrong size %d from mfsmaster", len(buf))
+			return true
+		}
+		return false
+	}
+	pos := 2
+	for pos < len(buf) {
+		s := new(SessionInfo)
+		var ver, leng uint32
+		if short(pos, 25) {
+			return
+		}
+		UnPack(buf[pos:], &s.Id, &s.Ip, &ver, &s.OpenFiles, &s.NSocks,
+			&s.Expire, &leng)
+		s.Version = GetVersion(ver)
+		pos += 25
+		if short(pos, int(leng)+4) {
+			return
+		}
+		s.Info = string(buf[pos : pos+int(leng)])
+		pos += int(leng)
+		UnPack(buf[pos:], &leng)
+		pos += 4
+		if short(pos, int(leng)+27+8*int(stats)) {
+			return
+		}
+		s.Path = string(buf[pos : pos+int(leng)])
+		pos += int(leng)
+		UnPack(buf[pos:], &s.Flags, &s.RootUid, &s.RootGid, &s.MapallUid,
+			&s.MapallGid, &s.MinGoal, &s.MaxGoal, &s.MinTrashTime, &s.MaxTrashTime)
+		pos += 27
+		s.Current = make([]uint32, stats)
+		s.Last = make([]uint32, stats)
+		UnPack(buf[pos:], s.Current, s.Last)
+		pos += 8 * int(stats)
+		sessions = append(sessions, s)
+		glog.V(10).Infof("list session id %d ip %s path %s", s.Id, s.IpStr(), s.Path)
+	}
+	return
+}
+
+// like mfscli -SMS
+func (c *MAClient) Sessions() (sessions []*SessionInfo, err error) {
+	buf, err := c.doCmd(CLTOMA_SESSION_LIST, uint8(2))
+	if err != nil {
+		return
+	}
+	sessions, err = parseSessions(buf)
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("list session number %d", len(sessions))
+	return
+}
+
+func (c *Client) Sessions() (sessions []*SessionInfo, err error) {
+	tools, err := c.Tools()
+	if err != nil {
+		return
+	}
+	return tools.Sessions()
+}
+
+func (c *Client) RemoveSession(sessionId uint32) (err error) {
+	tools, err := c.Tools()
+	if err != nil {
+		return
+	}
+	return tools.RemoveSession(sessionId)
+}
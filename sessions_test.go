@@ -0,0 +1,58 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestParseSessions(t *testing.T) {
+	stats := make([]uint32, 16)
+	stats[14] = 7
+	rec := func(id uint32, info, path string, flags uint8) []byte {
+		// 3.0.103 on the wire, the patch is shifted by the pro flag
+		buf := Pack(id, uint32(0x0a000001), uint32(ParseVersionInt(3, 0, 103<<1)),
+			uint32(3), uint8(1), uint32(0), uint32(len(info)))
+		buf = append(buf, info...)
+		buf = append(buf, Pack(uint32(len(path)))...)
+		buf = append(buf, path...)
+		buf = append(buf, Pack(flags, uint32(0), uint32(0), uint32(999),
+			uint32(999), uint8(1), uint8(9), uint32(0), uint32(0xffffffff))...)
+		buf = append(buf, Pack(stats, stats)...)
+		return buf
+	}
+	buf := Pack(uint16(16))
+	buf = append(buf, rec(1, "/mnt/mfs", "/", SESFLAG_READONLY|SESFLAG_MAPALL)...)
+	buf = append(buf, rec(2, "", "/data", SESFLAG_ADMIN)...)
+	sessions, err := parseSessions(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatal("unexpect sessions", len(sessions))
+	}
+	s := sessions[0]
+	if s.Id != 1 || s.IpStr() != "10.0.0.1" || s.Info != "/mnt/mfs" ||
+		s.OpenFiles != 3 || s.MaxGoal != 9 || s.Last[14] != 7 ||
+		s.Version.String() != "3.0.103" {
+		t.Error("unexpect", s)
+	}
+	if s.FlagsStr() != "ro,mapall=999:999" {
+		t.Error("unexpect flags", s.FlagsStr())
+	}
+	s = sessions[1]
+	if s.Id != 2 || s.Path != "/data" || s.FlagsStr() != "rw,admin,maproot=0:0" {
+		t.Error("unexpect", s, s.FlagsStr())
+	}
+	if _, err = parseSessions(buf[:len(buf)-1]); err == nil {
+		t.Error("expect error")
+	}
+	sessions, err = parseSessions(Pack(uint16(16)))
+	if err != nil || len(sessions) != 0 {
+		t.Error("unexpect empty", err)
+	}
+}
@@ -0,0 +1,156 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// for snapshot smode, as in MFSCommunication.h
+const (
+	SNAPSHOT_MODE_CAN_OVERWRITE      = 0x01
+	SNAPSHOT_MODE_CPLIKE_ATTR        = 0x02
+	SNAPSHOT_MODE_DELETE             = 0x04
+	SNAPSHOT_MODE_FORCE_REMOVAL      = 0x08
+	SNAPSHOT_MODE_PRESERVE_HARDLINKS = 0x10
+)
+
+// timestamp suffix of rotated snapshots, names are made with
+// microseconds, so rotations in the same second do not collide
+const (
+	SNAPSHOT_TIME_FORMAT = "20060102-150405"
+	SNAPSHOT_NAME_FORMAT = SNAPSHOT_TIME_FORMAT + ".000000"
+)
+
+func (c *MAClient) Snapshot(inode, inodeDst uint32, nameDst string,
+	smode uint8, umask uint16) (err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if err = checkInodeName(&inodeDst, &nameDst); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_SNAPSHOT, 0, inode, inodeDst,
+		uint8(len(nameDst)), nameDst, c.ids(), smode, umask)
+	if err != nil {
+		return
+	}
+	err = c.checkBuf(buf, 0, 5)
+	if err != nil {
+		return
+	}
+	err = getStatus(buf[4:])
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("snapshot inode %d to inode dst %d name dst %s smode %x",
+		inode, inodeDst, nameDst, smode)
+	return
+}
+
+// clone src file or tree to dst
+func (c *Client) Snapshot(src, dst string, overwrite bool, umask uint16) (err error) {
+	_, info, err := c.lookup(src)
+	if err != nil {
+		return
+	}
+	_, pinfo, err := c.lookup(filepath.Dir(dst))
+	if err != nil {
+		return
+	}
+	var smode uint8
+	if overwrite {
+		smode |= SNAPSHOT_MODE_CAN_OVERWRITE
+	}
+	return c.mc.Snapshot(info.Inode, pinfo.Inode, filepath.Base(dst), smode, umask)
+}
+
+// remove path and all its children
+func (c *Client) RemoveAll(path string) (err error) {
+	p, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		return c.mc.Unlink(p, filepath.Base(path))
+	}
+	entries, err := c.mc.ReaddirList(info.Inode, false)
+	if err != nil {
+		return
+	}
+	for _, f := range entries {
+		if f.Name == "." || f.Name == ".." {
+			continue
+		}
+		if err = c.RemoveAll(filepath.Join(path, f.Name)); err != nil {
+			return
+		}
+	}
+	return c.mc.Rmdir(p, filepath.Base(path))
+}
+
+// make a snapshot of src named <base of src>.<timestamp> in dir
+// and remove the oldest ones, keeping at most keep snapshots,
+// timestamps are UTC so names sort in time order across DST changes
+func (c *Client) RotateSnapshots(src, dir string, keep int,
+	umask uint16) (name string, err error) {
+	if keep < 1 {
+		err = fmt.Errorf("keep snapshots %d must be positive", keep)
+		return
+	}
+	if err = c.Mkdir(dir); err != nil {
+		return
+	}
+	prefix := filepath.Base(src) + "."
+	name = filepath.Join(dir, prefix+time.Now().UTC().Format(SNAPSHOT_NAME_FORMAT))
+	if err = c.Snapshot(src, name, false, umask); err != nil {
+		return
+	}
+	infoMap, err := c.Readdir(dir)
+	if err != nil {
+		return
+	}
+	names := make([]string, 0, len(infoMap))
+	for _, f := range infoMap {
+		names = append(names, f.Name)
+	}
+	for _, old := range oldSnapshots(names, prefix, keep) {
+		old = filepath.Join(dir, old)
+		glog.V(5).Infof("remove old snapshot %s", old)
+		if err = c.RemoveAll(old); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// names of snapshots beyond the newest keep ones, oldest first
+func oldSnapshots(names []string, prefix string, keep int) []string {
+	snaps := make([]string, 0)
+	for _, n := range names {
+		if !strings.HasPrefix(n, prefix) {
+			continue
+		}
+		// also accepts the fractional seconds of SNAPSHOT_NAME_FORMAT
+		_, err := time.Parse(SNAPSHOT_TIME_FORMAT, n[len(prefix):])
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, n)
+	}
+	if len(snaps) <= keep {
+		return nil
+	}
+	// the timestamp formats sort in time order
+	sort.Strings(snaps)
+	return snaps[:len(snaps)-keep]
+}
@@ -0,0 +1,89 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOldSnapshots(t *testing.T) {
+	names := []string{".", "..", "data.20190102-030405", "data.20190101-030405",
+		"data.20190103-030405", "data.notatime", "other.20190101-030405"}
+	old := oldSnapshots(names, "data.", 2)
+	if len(old) != 1 || old[0] != "data.20190101-030405" {
+		t.Error("unexpect", old)
+	}
+	old = oldSnapshots(names, "data.", 3)
+	if len(old) != 0 {
+		t.Error("unexpect", old)
+	}
+	// two names in the same second, and one without fraction
+	names = []string{"data.20190101-030405.000002", "data.20190101-030405.000001",
+		"data.20190101-030405", "data.20190101-030406.000000"}
+	old = oldSnapshots(names, "data.", 1)
+	if len(old) != 3 || old[0] != "data.20190101-030405" ||
+		old[2] != "data.20190101-030405.000002" {
+		t.Error("unexpect", old)
+	}
+	now := time.Date(2019, 1, 1, 3, 4, 5, 1000, time.UTC)
+	if name := now.Format(SNAPSHOT_NAME_FORMAT); name != "20190101-030405.000001" {
+		t.Error("unexpect", name)
+	}
+}
+
+func TestSnapshotMode(t *testing.T) {
+	// the smode byte follows the credentials on the wire
+	msg := PackCmd(CLTOMA_FUSE_SNAPSHOT, uint32(0), uint32(2), uint32(1),
+		uint8(1), "a", []uint32{0, 1, 0},
+		uint8(SNAPSHOT_MODE_PRESERVE_HARDLINKS|SNAPSHOT_MODE_CAN_OVERWRITE),
+		uint16(0))
+	if smode := msg[len(msg)-3]; smode != 0x11 {
+		t.Errorf("unexpect smode %#x", smode)
+	}
+	if SNAPSHOT_MODE_CPLIKE_ATTR != 2 || SNAPSHOT_MODE_DELETE != 4 ||
+		SNAPSHOT_MODE_FORCE_REMOVAL != 8 {
+		t.Error("unexpect snapshot modes")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	d := "/testsnapdir"
+	c.RemoveAll(d)
+	err = c.Mkdir(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.RemoveAll(d)
+	err = c.Snapshot(d, d+".snap", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Snapshot(d, d+".snap", false, 0)
+	if err == nil {
+		t.Fatal("unexpect")
+	}
+	err = c.Snapshot(d, d+".snap", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.RemoveAll(d + ".snap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.RotateSnapshots(d, "/testsnaps", 1, 022)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RemoveAll("/testsnaps")
+}
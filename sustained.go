@@ -0,0 +1,119 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sort"
+)
+
+// dtype of CLTOMA_FUSE_GETDETACHEDATTR
+const (
+	DTYPE_UNKNOWN   = 0
+	DTYPE_TRASH     = 1
+	DTYPE_SUSTAINED = 2
+)
+
+// a file in trash or sustained
+type DetachedEntry struct {
+	Name  string
+	Inode uint32
+	Info  *FileInfo
+}
+
+// N*[ name:NAME inode:32 ] of trash and sustained lists
+func parseNameInodes(buf []byte) (entries []*DetachedEntry, err error) {
+	entries = make([]*DetachedEntry, 0)
+	for pos := 0; pos < len(buf); {
+		sz := int(buf[pos])
+		pos++
+		if pos+sz+4 > len(buf) {
+			err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+			return
+		}
+		e := &DetachedEntry{Name: string(buf[pos : pos+sz])}
+		pos += sz
+		UnPack(buf[pos:], &e.Inode)
+		pos += 4
+		entries = append(entries, e)
+	}
+	return
+}
+
+// attr of a file in trash or sustained
+func (c *MAClient) GetDetachedAttr(inode uint32, dtype uint8) (fi *FileInfo, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_GETDETACHEDATTR, 0, inode, dtype)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 31)
+	if err != nil {
+		return
+	}
+	_, fi, err = parseFileInfo(inode, buf[4:])
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("get detached attr %d dtype %d", inode, dtype)
+	return
+}
+
+// sustained files sorted by name with their attrs,
+// mfsmaster answers only on a meta session, see MetaClient
+func (c *MAClient) Sustained() (entries []*DetachedEntry, err error) {
+	buf, err := c.doCmd(CLTOMA_FUSE_GETSUSTAINED, 0)
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 4)
+	if err != nil {
+		return
+	}
+	entries, err = parseNameInodes(buf[4:])
+	if err != nil {
+		return
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		e.Info, err = c.GetDetachedAttr(e.Inode, DTYPE_SUSTAINED)
+		if err == MFS_ERROR_ENOENT {
+			// closed after listing
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		kept = append(kept, e)
+	}
+	entries = kept
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	glog.V(8).Infof("sustained files %d", len(entries))
+	return
+}
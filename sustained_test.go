@@ -0,0 +1,45 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestParseNameInodes(t *testing.T) {
+	buf := append([]byte{3}, "abc"...)
+	buf = append(buf, Pack(uint32(10))...)
+	buf = append(buf, 0)
+	buf = append(buf, Pack(uint32(11))...)
+	entries, err := parseNameInodes(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Name != "abc" || entries[0].Inode != 10 ||
+		entries[1].Name != "" || entries[1].Inode != 11 {
+		t.Error("unexpect", entries)
+	}
+	if _, err = parseNameInodes(buf[:len(buf)-1]); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestSustained(t *testing.T) {
+	t.Skip()
+	m, err := NewMetaClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	entries, err := m.Sustained()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Log(e.Name, e.Inode, e.Info)
+	}
+}
@@ -0,0 +1,197 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"os"
+)
+
+type SyncStats struct {
+	Written uint64 // bytes sent to chunkservers
+	Skipped uint64 // bytes already the same
+}
+
+// checksum tab of a chunk which does not exist
+func emptyChecksumTab() (tab *ChecksumTab) {
+	tab = new(ChecksumTab)
+	for i := range tab {
+		tab[i] = MFSCRCEMPTY
+	}
+	return
+}
+
+// the first chunkserver to connect and the chain of the others
+func (d *CSData) writeChain() (first *CSItem, chain []interface{}) {
+	chain = []interface{}{d.ProtocolId, d.ChunkId, d.Version}
+	for _, cs := range d.CSItems {
+		if first == nil {
+			first = cs
+			continue
+		}
+		chain = append(chain, cs.Ip, cs.Port)
+	}
+	return
+}
+
+// write whole blocks of the chunk, data returns the content of block b
+func (d *CSData) WriteBlocks(blocks []uint16,
+	data func(b uint16) ([]byte, error)) (n uint64, err error) {
+	first, chain := d.writeChain()
+	if first == nil {
+		err = fmt.Errorf("no chunkserver found")
+		return
+	}
+	c, err := _cspool.Get(first)
+	if err != nil {
+		return
+	}
+	msg := PackCmd(CLTOCS_WRITE, chain...)
+	if err = c.Send(msg); err != nil {
+		err = fmt.Errorf("send write to cs error %v", err)
+		return
+	}
+	for i, b := range blocks {
+		var buf []byte
+		buf, err = data(b)
+		if err != nil {
+			c.Close()
+			return
+		}
+		err = d.WriteBlock(c, uint32(i+1), b, 0, buf)
+		if err != nil {
+			c.Close()
+			return
+		}
+		n += uint64(len(buf))
+	}
+	msg = PackCmd(CLTOCS_WRITE_FINISH, d.ChunkId, d.Version)
+	if err = c.Send(msg); err != nil {
+		err = fmt.Errorf("send write finish to cs error %v", err)
+		return
+	}
+	_cspool.Put(c)
+	return
+}
+
+// checksum tab of any copy of the chunk, nil if it can not be fetched
+func (c *Client) remoteChecksumTab(inode, index uint32) *ChecksumTab {
+	cs, err := c.mc.ReadChunk(inode, index, 0)
+	if err != nil {
+		return nil
+	}
+	if cs.ChunkId == 0 {
+		return emptyChecksumTab()
+	}
+	for _, item := range cs.CSItems {
+		tab, err := fetchChecksumTab(item, cs.ChunkId, cs.Version)
+		if err == nil {
+			return tab
+		}
+		glog.V(8).Infof("fetch checksum tab from %s error %v", item.addr(), err)
+	}
+	return nil
+}
+
+// upload local file to mfs like rsync, only the blocks whose crc32
+// differs from the chunkserver are written
+func (c *Client) SyncFile(localPath, path string) (st *SyncStats, err error) {
+	local, err := os.Open(localPath)
+	if err != nil {
+		return
+	}
+	defer local.Close()
+	linfo, err := local.Stat()
+	if err != nil {
+		return
+	}
+	size := uint64(linfo.Size())
+	file, err := c.OpenOrCreate(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	inode := file.info.Inode
+	if file.info.Size > size {
+		_, err = c.mc.Truncate(inode, TRUNCATE_FLAG_OPENED, size)
+		if err != nil {
+			return
+		}
+	}
+	st = new(SyncStats)
+	buf := make([]byte, MFSBLOCKSIZE)
+	for i := uint32(0); i < chunksOf(size); i++ {
+		var ltab *ChecksumTab
+		ltab, err = LocalChecksumTab(local, i)
+		if err != nil {
+			return
+		}
+		rtab := c.remoteChecksumTab(inode, i)
+		chunkOff := uint64(i) << MFSCHUNKBITS
+		blocks := make([]uint16, 0)
+		for b := 0; b < MFSBLOCKSINCHUNK; b++ {
+			off := chunkOff + uint64(b)<<MFSBLOCKBITS
+			if off >= size {
+				break
+			}
+			if rtab != nil && rtab[b] == ltab[b] {
+				bsize := size - off
+				if bsize > MFSBLOCKSIZE {
+					bsize = MFSBLOCKSIZE
+				}
+				st.Skipped += bsize
+				continue
+			}
+			blocks = append(blocks, uint16(b))
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		var cs *CSData
+		cs, err = c.mc.WriteChunk(inode, i, 0)
+		if err != nil {
+			err = fmt.Errorf("write chunk failed: %v", err)
+			return
+		}
+		var length uint64 // end of the last written block
+		n, e := cs.WriteBlocks(blocks, func(b uint16) ([]byte, error) {
+			off := chunkOff + uint64(b)<<MFSBLOCKBITS
+			rn, e := local.ReadAt(buf, int64(off))
+			if e != nil && e != io.EOF {
+				return nil, e
+			}
+			length = off + uint64(rn)
+			return buf[:rn], nil
+		})
+		// always end the write to unlock the chunk
+		err = c.mc.WriteChunkEnd(cs.ChunkId, inode, i, length, 0)
+		if e != nil {
+			err = fmt.Errorf("write blocks to chunkserver failed: %v", e)
+		}
+		if err != nil {
+			return
+		}
+		st.Written += n
+		glog.V(8).Infof("sync chunk %d write %d blocks", i, len(blocks))
+	}
+	// zero blocks at the end are not written
+	fi, err := c.mc.GetAttr(inode)
+	if err != nil {
+		return
+	}
+	if fi.Size != size {
+		_, err = c.mc.Truncate(inode, TRUNCATE_FLAG_OPENED, size)
+		if err != nil {
+			return
+		}
+	}
+	glog.V(5).Infof("sync file %s to mfs %s written %d skipped %d",
+		localPath, path, st.Written, st.Skipped)
+	return
+}
@@ -0,0 +1,78 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestWriteChain(t *testing.T) {
+	d := &CSData{
+		ProtocolId: 2,
+		ChunkId:    1,
+		Version:    1,
+		CSItems: CSItemMap{
+			1: &CSItem{Ip: 1, Port: 9422},
+			2: &CSItem{Ip: 2, Port: 9422},
+		},
+	}
+	first, chain := d.writeChain()
+	if first == nil {
+		t.Fatal("unexpect")
+	}
+	// protocolid chunkid version ip port
+	if len(chain) != 5 {
+		t.Fatal("unexpect", chain)
+	}
+	if chain[3].(uint32) == first.Ip {
+		t.Error("first chunkserver is in the chain")
+	}
+	if emptyChecksumTab()[MFSBLOCKSINCHUNK-1] != MFSCRCEMPTY {
+		t.Error("unexpect")
+	}
+}
+
+func TestSyncFile(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	data := make([]byte, 0x00300000)
+	rand.Read(data)
+	lname := "/tmp/syncfile889"
+	rname := "/syncfile889"
+	f, err := os.Create(lname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(lname)
+	f.Write(data)
+	f.Close()
+	defer c.Unlink(rname)
+	st, err := c.SyncFile(lname, rname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Written != uint64(len(data)) {
+		t.Fatal("unexpect ", st.Written)
+	}
+	data[MFSBLOCKSIZE] ^= 0xff
+	f, _ = os.Create(lname)
+	f.Write(data)
+	f.Close()
+	st, err = c.SyncFile(lname, rname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Written != MFSBLOCKSIZE {
+		t.Fatal("unexpect ", st.Written)
+	}
+}
@@ -7,6 +7,7 @@ Copyright (c) 2019 DHacky
 */
 
 import (
+	"encoding/binary"
 	"fmt"
 	"github.com/golang/glog"
 )
@@ -31,6 +32,143 @@ func (c *MAClient) MasterVersion() error {
 	return nil
 }
 
+// state of mfsmaster in MasterInfo
+const (
+	MASTER_STATE_DUMMY    = 0
+	MASTER_STATE_USURPER  = 1
+	MASTER_STATE_FOLLOWER = 2
+	MASTER_STATE_ELECT    = 3
+	MASTER_STATE_DEPUTY   = 4
+	MASTER_STATE_LEADER   = 5
+	MASTER_STATE_MASTER   = 0xFF // without HA
+)
+
+var masterStates = map[uint8]string{
+	MASTER_STATE_DUMMY:    "DUMMY",
+	MASTER_STATE_USURPER:  "USURPER",
+	MASTER_STATE_FOLLOWER: "FOLLOWER",
+	MASTER_STATE_ELECT:    "ELECT",
+	MASTER_STATE_DEPUTY:   "DEPUTY",
+	MASTER_STATE_LEADER:   "LEADER",
+	MASTER_STATE_MASTER:   "MASTER",
+}
+
+type MasterInfo struct {
+	Version           Version `json:"-"`
+	VersionStr        string  `json:"version"`
+	MemUsage          uint64  `json:"mem_usage"`
+	SysCpu            uint64  `json:"sys_cpu"`  // in microseconds per second
+	UserCpu           uint64  `json:"user_cpu"` // in microseconds per second
+	TotalSpace        uint64  `json:"total_space"`
+	AvailSpace        uint64  `json:"avail_space"`
+	FreeSpace         uint64  `json:"free_space"` // master sends 129 bytes or more
+	TrashSpace        uint64  `json:"trash_space"`
+	TrashNodes        uint32  `json:"trash_nodes"`
+	SustainedSpace    uint64  `json:"sustained_space"`
+	SustainedNodes    uint32  `json:"sustained_nodes"`
+	AllNodes          uint32  `json:"all_nodes"`
+	DirNodes          uint32  `json:"dir_nodes"`
+	FileNodes         uint32  `json:"file_nodes"`
+	Chunks            uint32  `json:"chunks"`
+	ChunkCopies       uint32  `json:"chunk_copies"`
+	TdCopies          uint32  `json:"td_copies"` // copies on disks marked for removal
+	LastStoreTs       uint32  `json:"last_store_ts"`
+	LastStoreDuration uint32  `json:"last_store_duration"` // in milliseconds
+	LastStoreStatus   uint8   `json:"last_store_status"`
+	State             uint8   `json:"state"`
+	NState            uint8   `json:"nstate"`
+	Stable            uint8   `json:"stable"`
+	Sync              uint8   `json:"sync"`
+	LeaderIp          uint32  `json:"leader_ip"`
+	StateChgTime      uint32  `json:"state_chg_time"`
+	MetaVersion       uint64  `json:"meta_version"`
+}
+
+func (info *MasterInfo) StateStr() string {
+	if s, ok := masterStates[info.State]; ok {
+		return s
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", info.State)
+}
+
+func (info *MasterInfo) LeaderIpStr() string {
+	return ipStr(info.LeaderIp)
+}
+
+// like mfscli -SIN, fields after laststore_status are zero
+// for mfsmaster older than 2.0.0
+func (c *MAClient) Info() (info *MasterInfo, err error) {
+	buf, err := c.doCmd(CLTOMA_INFO)
+	if err != nil {
+		return
+	}
+	return parseMasterInfo(buf)
+}
+
+func parseMasterInfo(buf []byte) (info *MasterInfo, err error) {
+	if len(buf) < 101 {
+		err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+		return
+	}
+	info = new(MasterInfo)
+	var ver uint32
+	UnPack(buf, &ver, &info.MemUsage, &info.SysCpu, &info.UserCpu,
+		&info.TotalSpace, &info.AvailSpace)
+	pos := 44
+	if len(buf) >= 129 {
+		UnPack(buf[pos:], &info.FreeSpace)
+		pos += 8
+	}
+	UnPack(buf[pos:], &info.TrashSpace, &info.TrashNodes,
+		&info.SustainedSpace, &info.SustainedNodes, &info.AllNodes,
+		&info.DirNodes, &info.FileNodes, &info.Chunks, &info.ChunkCopies,
+		&info.TdCopies, &info.LastStoreTs, &info.LastStoreDuration,
+		&info.LastStoreStatus)
+	pos += 57
+	if len(buf) >= pos+20 {
+		UnPack(buf[pos:], &info.State, &info.NState, &info.Stable, &info.Sync,
+			&info.LeaderIp, &info.StateChgTime, &info.MetaVersion)
+	}
+	info.Version = GetVersion(ver)
+	info.VersionStr = info.Version.String()
+	glog.V(8).Infof("master info version %s size %d", info.VersionStr, len(buf))
+	return
+}
+
+func (c *Client) Info() (info *MasterInfo, err error) {
+	tools, err := c.Tools()
+	if err != nil {
+		return
+	}
+	return tools.Info()
+}
+
+// matrixid of CLTOMA_CHUNKS_MATRIX
+const (
+	CHUNKS_MATRIX_ALL     = 0
+	CHUNKS_MATRIX_REGULAR = 1 // without copies marked for removal
+)
+
+// chunks count by goal and valid copies
+type ChunksMatrix [CHECK_MAX_COPIES + 1][CHECK_MAX_COPIES + 1]uint32
+
+// like the chunk matrix of mfscli -SIC
+func (c *MAClient) ChunksMatrix(matrixId uint8) (m *ChunksMatrix, err error) {
+	buf, err := c.doCmd(CLTOMA_CHUNKS_MATRIX, matrixId)
+	if err != nil {
+		return
+	}
+	m = new(ChunksMatrix)
+	if len(buf) != binary.Size(m) {
+		err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+		m = nil
+		return
+	}
+	UnPack(buf, m)
+	glog.V(8).Infof("chunks matrix %d", matrixId)
+	return
+}
+
 type QuotaInfo struct {
 	size                               int
 	inode                              uint32
@@ -48,37 +186,7 @@ type QuotaInfo struct {
 
 // get the max retio, only care soft quota
 func (info *QuotaInfo) Usage() (current, quota string, retio float64) {
-	c := float64(info.currlength)
-	q := float64(info.slength)
-	current = FormatBytes(c, Binary)
-	quota = FormatBytes(q, Binary)
-	if info.slength != 0 {
-		r := c / q
-		if r >= retio {
-			retio = r
-		}
-	}
-	if info.ssize != 0 {
-		c := float64(info.currsize)
-		q := float64(info.ssize)
-		r := c / q
-		if r > retio {
-			retio = r
-			current = FormatBytes(c, Binary)
-			quota = FormatBytes(q, Binary)
-		}
-	}
-	if info.sinodes != 0 {
-		c := float64(info.currinodes)
-		q := float64(info.sinodes)
-		r := c / q
-		if r > retio {
-			retio = r
-			current = FormatBytes(c, Decimal)
-			quota = FormatBytes(q, Decimal)
-		}
-	}
-	return
+	return info.Quota().Usage()
 }
 
 func (c *MAClient) UnPackQuota(buf []byte) *QuotaInfo {
@@ -0,0 +1,83 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestParseMasterInfo(t *testing.T) {
+	// 3.0.103 pro on the wire, the patch is shifted by the pro flag
+	ver := uint32(ParseVersionInt(3, 0, 103<<1|1))
+	head := Pack(ver, uint64(1<<30), uint64(1), uint64(2), uint64(100), uint64(50))
+	tail := Pack(uint64(3), uint32(4), uint64(5), uint32(6), uint32(7),
+		uint32(8), uint32(9), uint32(10), uint32(11), uint32(12),
+		uint32(13), uint32(14), uint8(0),
+		uint8(MASTER_STATE_MASTER), uint8(0), uint8(1), uint8(1),
+		uint32(0x7f000001), uint32(15), uint64(16))
+	base := make([]byte, 0, 121)
+	base = append(append(base, head...), tail...)
+	info, err := parseMasterInfo(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.VersionStr != "3.0.103" || info.AvailSpace != 50 ||
+		info.TrashSpace != 3 || info.Chunks != 10 || info.MetaVersion != 16 {
+		t.Error("unexpect", info)
+	}
+	if info.StateStr() != "MASTER" || info.LeaderIpStr() != "127.0.0.1" {
+		t.Error("unexpect state", info.StateStr(), info.LeaderIpStr())
+	}
+	// with freespace
+	buf := make([]byte, 0, 129)
+	buf = append(append(append(buf, head...), Pack(uint64(40))...), tail...)
+	info, err = parseMasterInfo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.FreeSpace != 40 || info.TrashSpace != 3 || info.MetaVersion != 16 {
+		t.Error("unexpect", info)
+	}
+	// before 2.0.0
+	info, err = parseMasterInfo(base[:101])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.LastStoreDuration != 14 || info.MetaVersion != 0 {
+		t.Error("unexpect", info)
+	}
+	if _, err = parseMasterInfo(buf[:50]); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestMasterInfo(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	info, err := c.Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(info.VersionStr, info.StateStr(), info.TotalSpace, info.AvailSpace)
+}
+
+func TestClosedClientTools(t *testing.T) {
+	c := new(Client)
+	if _, err := c.Statfs(); err == nil {
+		t.Error("expect error of closed client")
+	}
+	if _, err := c.Tools(); err == nil {
+		t.Error("expect error of closed client")
+	}
+	if _, err := c.Info(); err == nil {
+		t.Error("expect error of closed client")
+	}
+}
@@ -0,0 +1,196 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// crc32 of every block in chunk index of a local file, blocks are
+// padded with zero, blocks beyond the end are MFSCRCEMPTY
+func LocalChecksumTab(r io.ReaderAt, index uint32) (tab *ChecksumTab, err error) {
+	tab = new(ChecksumTab)
+	buf := make([]byte, MFSBLOCKSIZE)
+	off := int64(index) << MFSCHUNKBITS
+	eof := false
+	for i := range tab {
+		if eof {
+			tab[i] = MFSCRCEMPTY
+			continue
+		}
+		n, e := r.ReadAt(buf, off+int64(i)*MFSBLOCKSIZE)
+		if e == io.EOF {
+			eof = true
+		} else if e != nil {
+			err = e
+			return
+		}
+		for j := n; j < MFSBLOCKSIZE; j++ {
+			buf[j] = 0
+		}
+		tab[i] = crc32.ChecksumIEEE(buf)
+	}
+	return
+}
+
+// blocks which differ in two tabs
+func (tab *ChecksumTab) Diff(other *ChecksumTab) (blocks []uint16) {
+	for i := range tab {
+		if tab[i] != other[i] {
+			blocks = append(blocks, uint16(i))
+		}
+	}
+	return
+}
+
+func fetchChecksumTab(t *CSItem, chunkId uint64,
+	version uint32) (tab *ChecksumTab, err error) {
+	cs, err := _cspool.Get(t)
+	if err != nil {
+		return
+	}
+	tab, err = cs.ChecksumTab(chunkId, version)
+	if err != nil {
+		cs.Close()
+		return
+	}
+	_cspool.Put(cs)
+	return
+}
+
+type ReplicaVerify struct {
+	Replica *ChunkReplica
+	Err     error    // checksum tab could not be fetched
+	Stale   bool     // replica has a wrong chunk version
+	Blocks  []uint16 // blocks differ from the reference
+}
+
+func (rv *ReplicaVerify) OK() bool {
+	return rv.Err == nil && len(rv.Blocks) == 0
+}
+
+type ChunkVerify struct {
+	*ChunkInfo
+	Replicas []*ReplicaVerify
+}
+
+// false if no valid copies, or any copy is unreadable or divergent
+func (cv *ChunkVerify) OK() bool {
+	if cv.IsEmpty() {
+		return true
+	}
+	if cv.ValidCopies() == 0 {
+		return false
+	}
+	for _, rv := range cv.Replicas {
+		if !rv.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+type VerifyReport struct {
+	Path   string
+	Chunks []*ChunkVerify
+}
+
+func (r *VerifyReport) OK() bool {
+	for _, cv := range r.Chunks {
+		if !cv.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// the most common tab of all replicas
+func majorityTab(tabs []*ChecksumTab) (ref *ChecksumTab) {
+	count := make(map[ChecksumTab]int)
+	best := 0
+	for _, t := range tabs {
+		if t == nil {
+			continue
+		}
+		count[*t]++
+		if count[*t] > best {
+			best = count[*t]
+			ref = t
+		}
+	}
+	return
+}
+
+// compare checksum tabs of all replicas of the chunk with each other,
+// or with ref if it is not nil, empty chunks are skipped
+func verifyChunk(ci *ChunkInfo, ref *ChecksumTab) (cv *ChunkVerify) {
+	cv = &ChunkVerify{
+		ChunkInfo: ci,
+		Replicas:  make([]*ReplicaVerify, 0, len(ci.Replicas)),
+	}
+	if ci.IsEmpty() {
+		return
+	}
+	tabs := make([]*ChecksumTab, len(ci.Replicas))
+	for i, r := range ci.Replicas {
+		rv := &ReplicaVerify{Replica: r}
+		tabs[i], rv.Err = fetchChecksumTab(&CSItem{Ip: r.Ip, Port: r.Port},
+			ci.ChunkId, ci.Version)
+		rv.Stale = rv.Err == MFS_ERROR_WRONGVERSION
+		cv.Replicas = append(cv.Replicas, rv)
+	}
+	if ref == nil {
+		ref = majorityTab(tabs)
+	}
+	for i, rv := range cv.Replicas {
+		if tabs[i] != nil && ref != nil {
+			rv.Blocks = tabs[i].Diff(ref)
+		}
+		if !rv.OK() {
+			glog.V(5).Infof("chunk %d index %d replica %s err %v diff blocks %d",
+				ci.ChunkId, ci.Index, rv.Replica.Addr(), rv.Err, len(rv.Blocks))
+		}
+	}
+	return
+}
+
+// compare block checksums of all replicas of all chunks of path,
+// with the local reference copy if localPath is not empty
+func (c *Client) VerifyFile(path, localPath string) (report *VerifyReport, err error) {
+	var local *os.File
+	if len(localPath) > 0 {
+		local, err = os.Open(localPath)
+		if err != nil {
+			return
+		}
+		defer local.Close()
+	}
+	chunks, err := c.FileChunks(path)
+	if err != nil {
+		return
+	}
+	report = &VerifyReport{
+		Path:   path,
+		Chunks: make([]*ChunkVerify, 0, len(chunks)),
+	}
+	for _, ci := range chunks {
+		var ref *ChecksumTab
+		if local != nil {
+			ref, err = LocalChecksumTab(local, ci.Index)
+			if err != nil {
+				err = fmt.Errorf("checksum local file error %v", err)
+				return
+			}
+		}
+		report.Chunks = append(report.Chunks, verifyChunk(ci, ref))
+	}
+	return
+}
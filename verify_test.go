@@ -0,0 +1,89 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"bytes"
+	"hash/crc32"
+	"net"
+	"testing"
+)
+
+func TestLocalChecksumTab(t *testing.T) {
+	data := bytes.Repeat([]byte{1}, MFSBLOCKSIZE+10)
+	tab, err := LocalChecksumTab(bytes.NewReader(data), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tab[0] != crc32.ChecksumIEEE(data[:MFSBLOCKSIZE]) {
+		t.Error("unexpect block 0")
+	}
+	last := make([]byte, MFSBLOCKSIZE)
+	copy(last, data[MFSBLOCKSIZE:])
+	if tab[1] != crc32.ChecksumIEEE(last) {
+		t.Error("unexpect block 1")
+	}
+	if tab[2] != MFSCRCEMPTY || tab[MFSBLOCKSINCHUNK-1] != MFSCRCEMPTY {
+		t.Error("unexpect empty block")
+	}
+	tab, err = LocalChecksumTab(bytes.NewReader(data), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tab[0] != MFSCRCEMPTY {
+		t.Error("unexpect chunk 1")
+	}
+}
+
+func TestChecksumTabDiff(t *testing.T) {
+	a, b, c := new(ChecksumTab), new(ChecksumTab), new(ChecksumTab)
+	b[3] = 1
+	c[3] = 1
+	if len(a.Diff(a)) != 0 {
+		t.Error("unexpect")
+	}
+	d := a.Diff(b)
+	if len(d) != 1 || d[0] != 3 {
+		t.Error("unexpect", d)
+	}
+	if *majorityTab([]*ChecksumTab{a, nil, b, c}) != *b {
+		t.Error("unexpect majority")
+	}
+}
+
+func TestRecvCmdSize(t *testing.T) {
+	conn, cs := net.Pipe()
+	defer cs.Close()
+	c := &CSClient{conn: conn}
+	go cs.Write(Pack(uint32(CSTOAN_CHUNK_CHECKSUM_TAB), uint32(0xFFFFFFF0)))
+	if _, err := c.recvCmd(CSTOAN_CHUNK_CHECKSUM_TAB, 4108); err == nil {
+		t.Error("expect error of size")
+	}
+	conn, cs = net.Pipe()
+	defer cs.Close()
+	c = &CSClient{conn: conn}
+	go cs.Write(Pack(uint32(CSTOAN_CHUNK_CHECKSUM), uint32(2), uint16(7)))
+	if buf, err := c.recvCmd(CSTOAN_CHUNK_CHECKSUM, 13); err != nil || len(buf) != 2 {
+		t.Error("unexpect", buf, err)
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	t.Skip()
+	c, err := NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	report, err := c.VerifyFile("testrfile", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatal("unexpect")
+	}
+}
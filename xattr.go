@@ -0,0 +1,193 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/glog"
+)
+
+const MFS_XATTR_NAME_MAX = 255
+const MFS_XATTR_SIZE_MAX = 65536
+const MFS_XATTR_LIST_MAX = 65536
+
+// for setxattr mode
+const (
+	XATTR_CREATE_OR_REPLACE = iota
+	XATTR_CREATE_ONLY
+	XATTR_REPLACE_ONLY
+	XATTR_REMOVE
+)
+
+// for getxattr mode
+const (
+	XATTR_GET_DATA = iota
+	XATTR_LENGTH_ONLY
+)
+
+// empty name means list all names
+func (c *MAClient) getxattr(inode uint32, name string, mode,
+	opened uint8) (vleng uint32, value []byte, err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if len(name) > MFS_XATTR_NAME_MAX {
+		err = fmt.Errorf("xattr name %s length is too long", name)
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_GETXATTR, 0, inode, uint8(len(name)),
+		name, mode, opened, c.ids())
+	if err != nil {
+		return
+	}
+	if len(buf) == 5 {
+		err = c.checkBuf(buf, 0, 5)
+		if err != nil {
+			return
+		}
+		err = getStatus(buf[4:])
+		return
+	}
+	err = c.checkBuf(buf, 0, 8)
+	if err != nil {
+		return
+	}
+	UnPack(buf[4:], &vleng)
+	if mode == XATTR_LENGTH_ONLY {
+		return
+	}
+	if uint32(len(buf)) != vleng+8 {
+		err = fmt.Errorf("got wrong size %d from mfsmaster", len(buf))
+		return
+	}
+	value = buf[8:]
+	return
+}
+
+func (c *MAClient) Getxattr(inode uint32, name string,
+	opened uint8) (value []byte, err error) {
+	if len(name) == 0 {
+		err = fmt.Errorf("xattr name is empty")
+		return
+	}
+	_, value, err = c.getxattr(inode, name, XATTR_GET_DATA, opened)
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("getxattr inode %d name %s vleng %d", inode, name, len(value))
+	return
+}
+
+func (c *MAClient) Listxattr(inode uint32, opened uint8) (names []string, err error) {
+	_, buf, err := c.getxattr(inode, "", XATTR_GET_DATA, opened)
+	if err != nil {
+		return
+	}
+	names = parseXattrNames(buf)
+	glog.V(8).Infof("listxattr inode %d len %d", inode, len(names))
+	return
+}
+
+// names are separated by \0
+func parseXattrNames(buf []byte) (names []string) {
+	names = make([]string, 0)
+	for _, n := range bytes.Split(buf, []byte{0}) {
+		if len(n) > 0 {
+			names = append(names, string(n))
+		}
+	}
+	return
+}
+
+func (c *MAClient) Setxattr(inode uint32, name string, value []byte,
+	mode, opened uint8) (err error) {
+	if err = checkInodeName(&inode, nil); err != nil {
+		return
+	}
+	if len(name) == 0 || len(name) > MFS_XATTR_NAME_MAX {
+		err = fmt.Errorf("xattr name %s length is invalid", name)
+		return
+	}
+	if len(value) > MFS_XATTR_SIZE_MAX {
+		err = fmt.Errorf("xattr value length %d is too long", len(value))
+		return
+	}
+	if mode > XATTR_REMOVE {
+		err = fmt.Errorf("invalid xattr mode %d", mode)
+		return
+	}
+	buf, err := c.doCmd(CLTOMA_FUSE_SETXATTR, 0, inode, uint8(len(name)),
+		name, uint32(len(value)), value, mode, opened, c.ids())
+	if err != nil {
+		return
+	}
+	err = c.checkBuf(buf, 0, 5)
+	if err != nil {
+		return
+	}
+	err = getStatus(buf[4:])
+	if err != nil {
+		return
+	}
+	glog.V(8).Infof("setxattr inode %d name %s mode %d", inode, name, mode)
+	return
+}
+
+func (c *MAClient) Removexattr(inode uint32, name string, opened uint8) (err error) {
+	return c.Setxattr(inode, name, nil, XATTR_REMOVE, opened)
+}
+
+func (c *Client) Getxattr(path, name string) (value []byte, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.Getxattr(info.Inode, name, 0)
+}
+
+// mode is one of XATTR_CREATE_OR_REPLACE, XATTR_CREATE_ONLY
+// and XATTR_REPLACE_ONLY
+func (c *Client) Setxattr(path, name string, value []byte, mode uint8) (err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.Setxattr(info.Inode, name, value, mode, 0)
+}
+
+func (c *Client) Listxattr(path string) (names []string, err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.Listxattr(info.Inode, 0)
+}
+
+func (c *Client) Removexattr(path, name string) (err error) {
+	_, info, err := c.lookup(path)
+	if err != nil {
+		return
+	}
+	return c.mc.Removexattr(info.Inode, name, 0)
+}
+
+func (f *File) Getxattr(name string) (value []byte, err error) {
+	return f.client.mc.Getxattr(f.info.Inode, name, 1)
+}
+
+func (f *File) Setxattr(name string, value []byte, mode uint8) (err error) {
+	return f.client.mc.Setxattr(f.info.Inode, name, value, mode, 1)
+}
+
+func (f *File) Listxattr() (names []string, err error) {
+	return f.client.mc.Listxattr(f.info.Inode, 1)
+}
+
+func (f *File) Removexattr(name string) (err error) {
+	return f.client.mc.Removexattr(f.info.Inode, name, 1)
+}
@@ -0,0 +1,63 @@
+package mfscli
+
+/*
+MIT License
+
+Copyright (c) 2019 DHacky
+*/
+
+import (
+	"testing"
+)
+
+func TestParseXattrNames(t *testing.T) {
+	names := parseXattrNames([]byte("user.a\000user.bb\000"))
+	if len(names) != 2 || names[0] != "user.a" || names[1] != "user.bb" {
+		t.Error("unexpect", names)
+	}
+	names = parseXattrNames(nil)
+	if len(names) != 0 {
+		t.Error("unexpect", names)
+	}
+}
+
+func TestXattr(t *testing.T) {
+	t.Skip()
+	session(t, func(c *MAClient) {
+		n := "testfile"
+		c.Unlink(MFS_ROOT_ID, n)
+		fi, err := c.Create(MFS_ROOT_ID, n, 0744)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Unlink(MFS_ROOT_ID, n)
+		err = c.Setxattr(fi.Inode, "user.test", []byte("value"),
+			XATTR_CREATE_ONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.Setxattr(fi.Inode, "user.test", []byte("value"),
+			XATTR_CREATE_ONLY, 0)
+		if err == nil {
+			t.Fatal("unexpect")
+		}
+		v, err := c.Getxattr(fi.Inode, "user.test", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v) != "value" {
+			t.Fatal("unexpect ", string(v))
+		}
+		names, err := c.Listxattr(fi.Inode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(names) != 1 {
+			t.Fatal("unexpect ", names)
+		}
+		err = c.Removexattr(fi.Inode, "user.test", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}